@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"strings"
+
+	"github.com/chrisseto/scwl/pkg"
+	"github.com/cockroachdb/cockroach-go/v2/testserver"
+	"github.com/cockroachdb/errors"
+)
+
+// bisectTarget is a version of CockroachDB that a transcript may be replayed
+// against.
+type bisectTarget struct {
+	name string
+	opt  testserver.TestServerOpt
+}
+
+// bisect replays a transcript against an ordered list of CockroachDB
+// versions or binaries to find the first that fails to reproduce the
+// transcript's expected states.
+func bisect(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("bisect", flag.ExitOnError)
+	transcriptPath := flags.String("transcript", "", "path to a JSON transcript recorded by run")
+	versions := flags.String("versions", "", "comma separated, ordered, versions to bisect, eg: v22.2.0,v23.1.0..v23.1.12")
+	binaries := flags.String("binaries", "", "comma separated, ordered, paths to cockroach binaries to bisect, tested after -versions")
+	cacheDir := flags.String("cache-dir", "", "directory of cockroach-<version> binaries to use instead of downloading")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *transcriptPath == "" {
+		return errors.New("-transcript is required")
+	}
+
+	transcript, err := readTranscript(*transcriptPath)
+	if err != nil {
+		return err
+	}
+
+	targets, err := bisectTargets(*versions, *binaries, *cacheDir)
+	if err != nil {
+		return err
+	}
+
+	first, err := pkg.Bisect(len(targets), func(i int) (bool, error) {
+		log.Printf("Replaying against %s", targets[i].name)
+
+		runErr, err := replay(ctx, transcript, targets[i].opt)
+		if err != nil {
+			return false, errors.Wrapf(err, "replaying against %s", targets[i].name)
+		}
+
+		if runErr != nil {
+			log.Printf("%s: FAIL: %v", targets[i].name, runErr)
+		} else {
+			log.Printf("%s: PASS", targets[i].name)
+		}
+		return runErr != nil, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case first == len(targets):
+		log.Printf("No failing version, last passing: %s", targets[len(targets)-1].name)
+	case first == 0:
+		log.Printf("First failing: %s, no passing version", targets[0].name)
+	default:
+		log.Printf("First failing: %s, last passing: %s", targets[first].name, targets[first-1].name)
+	}
+
+	return nil
+}
+
+// bisectTargets returns the versions followed by the binaries to bisect.
+// Versions with a binary in cacheDir use it rather than being downloaded.
+func bisectTargets(versions, binaries, cacheDir string) ([]bisectTarget, error) {
+	expanded, err := pkg.ExpandVersions(versions)
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []bisectTarget
+	for _, version := range expanded {
+		targets = append(targets, bisectTarget{name: version, opt: versionOpt(cacheDir, version)})
+	}
+
+	for _, path := range strings.Split(binaries, ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			targets = append(targets, bisectTarget{name: path, opt: testserver.CockroachBinaryPathOpt(path)})
+		}
+	}
+
+	if len(targets) == 0 {
+		return nil, errors.New("at least one of -versions or -binaries is required")
+	}
+	return targets, nil
+}
+
+// replay runs transcript against a fresh testserver. runErr is the failure,
+// if any, of the transcript itself while err indicates that the testserver
+// couldn't be run at all.
+func replay(ctx context.Context, transcript *pkg.Transcript, opt testserver.TestServerOpt) (runErr, err error) {
+	ts, err := testserver.NewTestServer(opt)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer ts.Stop()
+
+	db, err := openSystemDB(ts)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	sut := pkg.NewSUT(db, log.New(NopWriter{}, "", 0))
+	return transcript.Run(ctx, sut), nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/chrisseto/scwl/pkg"
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+)
+
+// chaosMonkey stops and restarts nodes of a multi-node SUT cluster between or
+// during the execution of Commands. The gateway is never stopped as the SUT's
+// connections are made through it.
+type chaosMonkey struct {
+	ts     *cluster
+	db     *sqlx.DB
+	rate   float64
+	logger *log.Logger
+}
+
+func newChaosMonkey(ts *cluster, rate float64, logger *log.Logger) (*chaosMonkey, error) {
+	if ts.Nodes() < 2 {
+		return nil, errors.Newf("chaos requires a multi-node cluster, got %d nodes", ts.Nodes())
+	}
+
+	db, err := openSystemDB(ts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &chaosMonkey{ts: ts, db: db, rate: rate, logger: logger}, nil
+}
+
+// Around runs fn, with probability rate stopping a node either before or
+// while fn runs. The stopped node is restarted before Around returns. fnErr
+// is the result of fn while err indicates a failure to control the cluster.
+func (c *chaosMonkey) Around(ctx context.Context, fn func() error) (fnErr, err error) {
+	if rand.Float64() >= c.rate {
+		return fn(), nil
+	}
+
+	if pkg.FlipCoin() {
+		node := c.target(ctx)
+		c.logger.Printf("\tChaos: restarting node %d before executing", node)
+		if err := c.restart(node); err != nil {
+			return nil, err
+		}
+		return fn(), nil
+	}
+
+	// Stop a node shortly after fn begins executing, hopefully while a job
+	// is running.
+	stopped := make(chan int, 1)
+	stopErr := make(chan error, 1)
+	go func() {
+		time.Sleep(time.Duration(rand.Intn(250)) * time.Millisecond)
+		node := c.target(ctx)
+		c.logger.Printf("\tChaos: stopping node %d while executing", node)
+		stopped <- node
+		stopErr <- c.ts.StopNode(node)
+	}()
+
+	fnErr = fn()
+
+	node := <-stopped
+	if err := <-stopErr; err != nil {
+		return nil, errors.Wrapf(err, "stopping node %d", node)
+	}
+	return fnErr, c.start(node)
+}
+
+// target returns the node coordinating a running schema change job, if it's
+// not the gateway, otherwise a random non-gateway node. Node IDs are assumed
+// to be one greater than the testserver's node index.
+func (c *chaosMonkey) target(ctx context.Context) int {
+	const coordinatorsQuery = `SELECT coordinator_id - 1
+	FROM crdb_internal.jobs
+	WHERE job_type IN ('SCHEMA CHANGE', 'NEW SCHEMA CHANGE')
+	AND status = 'running' AND coordinator_id IS NOT NULL
+	`
+
+	var coordinators []int
+	// Failing to find a coordinator isn't fatal, fall back to a random node.
+	_ = c.db.SelectContext(ctx, &coordinators, coordinatorsQuery)
+
+	return pickTarget(coordinators, c.ts.Nodes(), c.ts.Gateway())
+}
+
+// pickTarget returns the first of coordinators that's a node of a cluster of
+// nodes nodes, other than gateway. If there is none, a random node other than
+// gateway is returned.
+func pickTarget(coordinators []int, nodes, gateway int) int {
+	for _, node := range coordinators {
+		if node >= 0 && node < nodes && node != gateway {
+			return node
+		}
+	}
+
+	node := rand.Intn(nodes - 1)
+	if node >= gateway {
+		node++
+	}
+	return node
+}
+
+func (c *chaosMonkey) restart(node int) error {
+	if err := c.ts.StopNode(node); err != nil {
+		return errors.Wrapf(err, "stopping node %d", node)
+	}
+	return c.start(node)
+}
+
+func (c *chaosMonkey) start(node int) error {
+	if err := c.ts.StartNode(node); err != nil {
+		return errors.Wrapf(err, "starting node %d", node)
+	}
+	return errors.Wrapf(c.ts.WaitForInitFinishForNode(node), "waiting for node %d", node)
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cockroachdb/cockroach-go/v2/testserver"
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+)
+
+// testserverNodes is the largest cluster that testserver is able to start by
+// itself.
+const testserverNodes = 3
+
+// gatewayNode is the node that SQL connections to multi-node clusters are
+// made through. It's not node 0 as node 0 usually coordinates schema change
+// jobs, which should be possible to stop without losing the connection.
+const gatewayNode = 1
+
+// clusterConfig configures the cluster started by [startCluster].
+type clusterConfig struct {
+	// Nodes is the number of nodes in the cluster.
+	Nodes int
+	// Binary is the cockroach binary run by nodes beyond testserver's and
+	// UpgradeBinary is the one they're upgraded to, if any.
+	Binary, UpgradeBinary string
+	// ExternalIODir is the --external-io-dir of nodes beyond testserver's. It
+	// should match testserver.ExternalIODirOpt, if given.
+	ExternalIODir string
+}
+
+// cluster is a testserver.TestServer of any number of nodes. testserver only
+// supports single and three node clusters, so nodes beyond the third are run
+// separately and joined to testserver's. PGURL refers to the gateway node.
+type cluster struct {
+	testserver.TestServer
+	nodes int
+	extra []*extraNode
+}
+
+// startCluster starts a cluster of cfg.Nodes nodes. opts must specify the
+// version to run, which must match cfg.Binary if there are more than
+// testserverNodes nodes. The cluster is stopped once ctx is done.
+func startCluster(ctx context.Context, cfg clusterConfig, opts ...testserver.TestServerOpt) (*cluster, error) {
+	if cfg.Nodes != 1 && cfg.Nodes < testserverNodes {
+		return nil, errors.Newf("clusters of %d nodes aren't supported", cfg.Nodes)
+	}
+	if cfg.Nodes > testserverNodes && cfg.Binary == "" {
+		return nil, errors.Newf("clusters of more than %d nodes require a binary", testserverNodes)
+	}
+	if cfg.Nodes > 1 {
+		opts = append(opts, testserver.ThreeNodeOpt())
+	}
+
+	ts, err := testserver.NewTestServer(opts...)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	c := &cluster{TestServer: ts, nodes: cfg.Nodes}
+	go func() {
+		<-ctx.Done()
+		c.Stop()
+	}()
+
+	for i := testserverNodes; i < cfg.Nodes; i++ {
+		n, err := newExtraNode(cfg)
+		if err != nil {
+			return nil, err
+		}
+		c.extra = append(c.extra, n)
+
+		if err := c.StartNode(i); err != nil {
+			return nil, errors.Wrapf(err, "starting node %d", i)
+		}
+		if err := c.WaitForInitFinishForNode(i); err != nil {
+			return nil, errors.Wrapf(err, "waiting for node %d", i)
+		}
+	}
+
+	return c, nil
+}
+
+// Nodes returns the number of nodes in c.
+func (c *cluster) Nodes() int {
+	return c.nodes
+}
+
+// Gateway returns the node that SQL connections are made through.
+func (c *cluster) Gateway() int {
+	if c.nodes == 1 {
+		return 0
+	}
+	return gatewayNode
+}
+
+func (c *cluster) PGURL() *url.URL {
+	return c.PGURLForNode(c.Gateway())
+}
+
+func (c *cluster) PGURLForNode(i int) *url.URL {
+	if n, ok := c.extraNode(i); ok {
+		return n.pgURL()
+	}
+	return c.TestServer.PGURLForNode(i)
+}
+
+func (c *cluster) StartNode(i int) error {
+	n, ok := c.extraNode(i)
+	if !ok {
+		return c.TestServer.StartNode(i)
+	}
+
+	// Nodes join through the gateway and any other extra nodes, whose
+	// addresses are fixed.
+	join := []string{c.PGURLForNode(c.Gateway()).Host}
+	for _, other := range c.extra {
+		if other != n {
+			join = append(join, other.pgURL().Host)
+		}
+	}
+	return n.start(join)
+}
+
+func (c *cluster) StopNode(i int) error {
+	if n, ok := c.extraNode(i); ok {
+		return n.stop()
+	}
+	return c.TestServer.StopNode(i)
+}
+
+func (c *cluster) UpgradeNode(i int) error {
+	n, ok := c.extraNode(i)
+	if !ok {
+		return c.TestServer.UpgradeNode(i)
+	}
+	if n.upgradeBinary == "" {
+		return errors.Newf("node %d has no binary to upgrade to", i)
+	}
+	if err := n.stop(); err != nil {
+		return err
+	}
+	n.binary = n.upgradeBinary
+	return c.StartNode(i)
+}
+
+func (c *cluster) WaitForInitFinishForNode(i int) error {
+	n, ok := c.extraNode(i)
+	if !ok {
+		return c.TestServer.WaitForInitFinishForNode(i)
+	}
+	return n.waitForInit()
+}
+
+func (c *cluster) Stop() {
+	for _, n := range c.extra {
+		_ = n.stop()
+		_ = os.RemoveAll(n.dir)
+	}
+	c.TestServer.Stop()
+}
+
+func (c *cluster) extraNode(i int) (*extraNode, bool) {
+	if i < testserverNodes || i-testserverNodes >= len(c.extra) {
+		return nil, false
+	}
+	return c.extra[i-testserverNodes], true
+}
+
+// testserverEnv is the environment that testserver (v2.3.5) starts its nodes
+// with. Nodes joining testserver's must match it, eg: nodes with differing
+// maximum clock offsets can't form a cluster.
+var testserverEnv = []string{
+	"COCKROACH_MAX_OFFSET=1ns",
+	"COCKROACH_TRUST_CLIENT_PROVIDED_SQL_REMOTE_ADDR=true",
+}
+
+// testserverExternalIODir is testserver's default --external-io-dir, which
+// disables access to the local filesystem by BACKUP, IMPORT, etc.
+const testserverExternalIODir = "disabled"
+
+// extraNode is a node of a cluster that's run outside of testserver. Its
+// store is written to disk and its port is fixed, so it may be restarted.
+type extraNode struct {
+	binary, upgradeBinary string
+	dir                   string
+	externalIODir         string
+	port                  int
+	cmd                   *exec.Cmd
+}
+
+func newExtraNode(cfg clusterConfig) (*extraNode, error) {
+	dir, err := os.MkdirTemp("", "scwl-node")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	port, err := freePort()
+	if err != nil {
+		return nil, err
+	}
+
+	externalIODir := cfg.ExternalIODir
+	if externalIODir == "" {
+		externalIODir = testserverExternalIODir
+	}
+
+	return &extraNode{
+		binary:        cfg.Binary,
+		upgradeBinary: cfg.UpgradeBinary,
+		dir:           dir,
+		externalIODir: externalIODir,
+		port:          port,
+	}, nil
+}
+
+func (n *extraNode) pgURL() *url.URL {
+	return &url.URL{
+		Scheme:   "postgresql",
+		User:     url.User("root"),
+		Host:     fmt.Sprintf("localhost:%d", n.port),
+		RawQuery: "sslmode=disable",
+	}
+}
+
+func (n *extraNode) start(join []string) error {
+	if n.cmd != nil {
+		return errors.New("node already running")
+	}
+
+	logs, err := os.OpenFile(filepath.Join(n.dir, "cockroach.log"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer logs.Close()
+
+	cmd := exec.Command(n.binary, n.args(join)...)
+	cmd.Dir = n.dir
+	cmd.Stdout = logs
+	cmd.Stderr = logs
+	cmd.Env = append(os.Environ(), testserverEnv...)
+
+	if err := cmd.Start(); err != nil {
+		return errors.WithStack(err)
+	}
+	n.cmd = cmd
+	return nil
+}
+
+// args returns the arguments that n's binary is started with, joining the
+// cluster through the addresses in join.
+func (n *extraNode) args(join []string) []string {
+	return []string{
+		"start",
+		"--logtostderr",
+		"--insecure",
+		"--store=path=" + filepath.Join(n.dir, "store"),
+		fmt.Sprintf("--listen-addr=localhost:%d", n.port),
+		"--http-addr=localhost:0",
+		"--external-io-dir=" + n.externalIODir,
+		"--join=" + strings.Join(join, ","),
+	}
+}
+
+func (n *extraNode) stop() error {
+	if n.cmd == nil {
+		return nil
+	}
+
+	cmd := n.cmd
+	n.cmd = nil
+
+	// As with testserver's nodes, the process is terminated rather than
+	// killed. Its exit status is uninteresting.
+	if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		return errors.WithStack(err)
+	}
+	_ = cmd.Wait()
+	return nil
+}
+
+func (n *extraNode) waitForInit() error {
+	db, err := sqlx.Open("pgx", n.pgURL().String())
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	for {
+		if _, err := db.ExecContext(ctx, `SELECT 1`); err == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.Wrapf(ctx.Err(), "waiting for node on port %d", n.port)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// freePort returns a port that's currently free for listening on localhost.
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClusterNodes(t *testing.T) {
+	single := &cluster{nodes: 1}
+	require.Equal(t, 0, single.Gateway())
+
+	c := &cluster{nodes: 5, extra: []*extraNode{{port: 1}, {port: 2}}}
+	require.Equal(t, 1, c.Gateway())
+	require.Equal(t, 5, c.Nodes())
+
+	for i := 0; i < testserverNodes; i++ {
+		_, ok := c.extraNode(i)
+		require.False(t, ok, "node %d is testserver's", i)
+	}
+	for i, port := range []int{1, 2} {
+		n, ok := c.extraNode(testserverNodes + i)
+		require.True(t, ok)
+		require.Equal(t, port, n.port)
+	}
+	_, ok := c.extraNode(5)
+	require.False(t, ok)
+}
+
+func TestExtraNodeArgs(t *testing.T) {
+	n := &extraNode{dir: "/tmp/node", externalIODir: "/tmp/backups", port: 26260}
+	require.Equal(t, []string{
+		"start",
+		"--logtostderr",
+		"--insecure",
+		"--store=path=/tmp/node/store",
+		"--listen-addr=localhost:26260",
+		"--http-addr=localhost:0",
+		"--external-io-dir=/tmp/backups",
+		"--join=localhost:26257,localhost:26261",
+	}, n.args([]string{"localhost:26257", "localhost:26261"}))
+}
+
+func TestPickTarget(t *testing.T) {
+	// The first coordinator that's a node other than the gateway.
+	require.Equal(t, 3, pickTarget([]int{1, -1, 5, 3, 4}, 5, 1))
+	require.Equal(t, 0, pickTarget([]int{0}, 3, 1))
+
+	// Otherwise a random node other than the gateway.
+	seen := map[int]bool{}
+	for i := 0; i < 1000; i++ {
+		node := pickTarget([]int{1}, 5, 1)
+		require.NotEqual(t, 1, node)
+		require.True(t, node >= 0 && node < 5)
+		seen[node] = true
+	}
+	require.Len(t, seen, 4)
+}
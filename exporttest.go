@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+
+	"github.com/chrisseto/scwl/pkg"
+	"github.com/cockroachdb/errors"
+)
+
+// readTranscript decodes the JSON transcript at path.
+func readTranscript(path string) (*pkg.Transcript, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var transcript pkg.Transcript
+	if err := json.Unmarshal(data, &transcript); err != nil {
+		return nil, errors.Wrapf(err, "decoding %s", path)
+	}
+	return &transcript, nil
+}
+
+// exportTest converts a recorded transcript into a standalone Go regression
+// test.
+func exportTest(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("export-test", flag.ExitOnError)
+	transcriptPath := flags.String("transcript", "", "path to a JSON transcript recorded by run")
+	out := flags.String("o", "", "path to write the generated test to, defaults to stdout")
+	pkgName := flags.String("package", "regression", "package clause of the generated test")
+	name := flags.String("name", "TestRepro", "name of the generated test function")
+	version := flags.String("version", "", "version of CockroachDB to test against, defaults to the version recorded in the transcript")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *transcriptPath == "" {
+		return errors.New("-transcript is required")
+	}
+
+	transcript, err := readTranscript(*transcriptPath)
+	if err != nil {
+		return err
+	}
+
+	w := os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	return transcript.WriteGoTest(w, pkg.GoTestOptions{
+		Package: *pkgName,
+		Name:    *name,
+		Version: *version,
+	})
+}
+
+// exportLogicTest converts a recorded transcript into a CockroachDB logictest
+// file.
+func exportLogicTest(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("export-logictest", flag.ExitOnError)
+	transcriptPath := flags.String("transcript", "", "path to a JSON transcript recorded by run")
+	out := flags.String("o", "", "path to write the generated logictest to, defaults to stdout")
+	everyStep := flags.Bool("every-step", false, "assert the expected state after every step rather than only the last")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *transcriptPath == "" {
+		return errors.New("-transcript is required")
+	}
+
+	transcript, err := readTranscript(*transcriptPath)
+	if err != nil {
+		return err
+	}
+
+	w := os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	return transcript.WriteLogicTest(w, pkg.LogicTestOptions{CheckEveryStep: *everyStep})
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/cockroachdb/cockroach-go/v2/testserver"
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+)
+
+// jobFault is an action taken against a running schema change job.
+type jobFault string
+
+const (
+	jobFaultPauseResume jobFault = "pause-resume"
+	jobFaultCancel      jobFault = "cancel"
+	jobFaultPauseCancel jobFault = "pause-cancel"
+)
+
+var jobFaults = []jobFault{jobFaultPauseResume, jobFaultCancel, jobFaultPauseCancel}
+
+// jobFaulter pauses, resumes and cancels the schema change jobs created by
+// Commands executed against the SUT.
+type jobFaulter struct {
+	db     *sqlx.DB
+	rate   float64
+	logger *log.Logger
+}
+
+func newJobFaulter(ts testserver.TestServer, rate float64, logger *log.Logger) (*jobFaulter, error) {
+	db, err := openSystemDB(ts)
+	if err != nil {
+		return nil, err
+	}
+	return &jobFaulter{db: db, rate: rate, logger: logger}, nil
+}
+
+// Around runs fn in the background and, with probability rate, injects a
+// jobFault into the first schema change job that it creates. Once fn has
+// returned, Around waits for all of the jobs created by fn to reach a
+// terminal state. succeeded reports whether they all succeeded and is only
+// meaningful if found is true. err indicates a failure to observe the jobs.
+func (j *jobFaulter) Around(ctx context.Context, fn func() error) (fnErr error, succeeded, found bool, err error) {
+	var start time.Time
+	if err := j.db.GetContext(ctx, &start, `SELECT now()`); err != nil {
+		return nil, false, false, errors.WithStack(err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- fn() }()
+
+	if rand.Float64() < j.rate {
+		fault := jobFaults[rand.Intn(len(jobFaults))]
+
+	poll:
+		for {
+			select {
+			case fnErr = <-done:
+				done <- fnErr
+				break poll
+			case <-time.After(10 * time.Millisecond):
+			}
+
+			ids, err := j.jobs(ctx, start)
+			if err != nil {
+				return nil, false, false, err
+			}
+			if len(ids) > 0 {
+				j.inject(ctx, ids[0], fault)
+				break
+			}
+		}
+	}
+
+	fnErr = <-done
+
+	ids, err := j.jobs(ctx, start)
+	if err != nil || len(ids) == 0 {
+		return fnErr, false, false, err
+	}
+
+	succeeded, err = j.wait(ctx, ids)
+	return fnErr, succeeded, true, err
+}
+
+// jobs returns the IDs of the schema change jobs created since start.
+func (j *jobFaulter) jobs(ctx context.Context, start time.Time) ([]int64, error) {
+	const jobsQuery = `SELECT job_id
+	FROM crdb_internal.jobs
+	WHERE job_type IN ('SCHEMA CHANGE', 'NEW SCHEMA CHANGE') AND created >= $1
+	ORDER BY created
+	`
+
+	var ids []int64
+	err := j.db.SelectContext(ctx, &ids, jobsQuery, start)
+	return ids, errors.WithStack(err)
+}
+
+// statements returns the statements, parameterized by a job ID, that apply f
+// in order.
+func (f jobFault) statements() []string {
+	switch f {
+	case jobFaultPauseResume:
+		return []string{pauseJob, `RESUME JOB $1`}
+	case jobFaultCancel:
+		return []string{`CANCEL JOB $1`}
+	case jobFaultPauseCancel:
+		return []string{pauseJob, `CANCEL JOB $1`}
+	default:
+		panic(errors.Newf("unknown job fault %q", f))
+	}
+}
+
+const pauseJob = `PAUSE JOB $1`
+
+// inject applies fault to the job id. Not all jobs may be paused or
+// cancelled, eg: once a drop has begun, so errors are logged rather than
+// returned. Paused jobs are left paused for a short, random, duration.
+func (j *jobFaulter) inject(ctx context.Context, id int64, fault jobFault) {
+	j.logger.Printf("\tJob Fault: %s job %d", fault, id)
+
+	for _, stmt := range fault.statements() {
+		if _, err := j.db.ExecContext(ctx, stmt, id); err != nil {
+			j.logger.Printf("\tJob Fault: %s: %v", stmt, err)
+			return
+		}
+
+		if stmt == pauseJob {
+			if err := j.waitForStatus(ctx, id, "paused"); err != nil {
+				j.logger.Printf("\tJob Fault: %v", err)
+			}
+			time.Sleep(time.Duration(rand.Intn(500)) * time.Millisecond)
+		}
+	}
+}
+
+// isTerminal reports whether a job of status will make no further progress.
+func isTerminal(status string) bool {
+	switch status {
+	case "succeeded", "failed", "canceled":
+		return true
+	default:
+		return false
+	}
+}
+
+// allSucceeded reports whether every one of statuses, the terminal statuses
+// of a Command's jobs, is succeeded.
+func allSucceeded(statuses []string) bool {
+	for _, status := range statuses {
+		if status != "succeeded" {
+			return false
+		}
+	}
+	return true
+}
+
+// waitForStatus polls the job id until it reaches status or a terminal state.
+func (j *jobFaulter) waitForStatus(ctx context.Context, id int64, status string) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	for {
+		var current string
+		if err := j.db.GetContext(ctx, &current, `SELECT status FROM crdb_internal.jobs WHERE job_id = $1`, id); err != nil {
+			return errors.Wrapf(err, "waiting for job %d to be %s", id, status)
+		}
+
+		if current == status || isTerminal(current) {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.Wrapf(ctx.Err(), "waiting for job %d to be %s", id, status)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+// wait blocks until all jobs in ids are terminal and reports whether they
+// all succeeded.
+func (j *jobFaulter) wait(ctx context.Context, ids []int64) (bool, error) {
+	var statuses []string
+	for _, id := range ids {
+		if err := j.waitForStatus(ctx, id, "succeeded"); err != nil {
+			return false, err
+		}
+
+		var status string
+		if err := j.db.GetContext(ctx, &status, `SELECT status FROM crdb_internal.jobs WHERE job_id = $1`, id); err != nil {
+			return false, errors.WithStack(err)
+		}
+		j.logger.Printf("\tJob %d: %s", id, status)
+		statuses = append(statuses, status)
+	}
+	return allSucceeded(statuses), nil
+}
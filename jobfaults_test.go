@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobFaultStatements(t *testing.T) {
+	require.Equal(t, []string{`PAUSE JOB $1`, `RESUME JOB $1`}, jobFaultPauseResume.statements())
+	require.Equal(t, []string{`CANCEL JOB $1`}, jobFaultCancel.statements())
+	require.Equal(t, []string{`PAUSE JOB $1`, `CANCEL JOB $1`}, jobFaultPauseCancel.statements())
+
+	for _, fault := range jobFaults {
+		require.NotEmpty(t, fault.statements(), fault)
+	}
+	require.Panics(t, func() { jobFault("unknown").statements() })
+}
+
+func TestJobOutcomes(t *testing.T) {
+	for _, status := range []string{"succeeded", "failed", "canceled"} {
+		require.True(t, isTerminal(status), status)
+	}
+	for _, status := range []string{"running", "paused", "pause-requested", "reverting", "cancel-requested"} {
+		require.False(t, isTerminal(status), status)
+	}
+
+	require.True(t, allSucceeded([]string{"succeeded", "succeeded"}))
+	require.False(t, allSucceeded([]string{"succeeded", "canceled"}))
+	require.False(t, allSucceeded([]string{"failed"}))
+}
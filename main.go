@@ -2,18 +2,18 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
-	"math/rand"
 	"os"
 	"os/signal"
-	"time"
+	"path/filepath"
+	"strings"
 
 	"github.com/chrisseto/scwl/pkg"
 	"github.com/chrisseto/scwl/pkg/dag"
 	"github.com/cockroachdb/cockroach-go/v2/testserver"
-	"github.com/google/go-cmp/cmp"
-	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/cockroachdb/errors"
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/jmoiron/sqlx"
 )
@@ -29,22 +29,30 @@ func MustT[T any](r T, err error) T {
 	return r
 }
 
-func NewSUT(ctx context.Context) pkg.System {
+// Use 23.1.0 to target https://github.com/cockroachdb/cockroach/pull/107633
+// Seed: 1693416869569725000 will produce a reproduction at eed69fee47857c2a3d50b47878180b4a1f198bd6
+const defaultVersion = "v23.1.0"
+
+// NewSUT starts a cluster of the version specified by version, see
+// [versionOpt], as configured by cfg. The returned cluster may be used to
+// control the nodes of multi-node clusters.
+func NewSUT(ctx context.Context, version testserver.TestServerOpt, cfg clusterConfig, opts ...testserver.TestServerOpt) (pkg.System, *cluster) {
 	logger := log.New(NopWriter{}, "", 0)
 	// logger := log.Default()
 
-	// Use 23.1.0 to target https://github.com/cockroachdb/cockroach/pull/107633
-	// Seed: 1693416869569725000 will produce a reproduction at eed69fee47857c2a3d50b47878180b4a1f198bd6
-	sutTS := MustT(testserver.NewTestServer(testserver.CustomVersionOpt("v23.1.0")))
-	go func() {
-		<-ctx.Done()
-		sutTS.Stop()
-	}()
+	opts = append([]testserver.TestServerOpt{version}, opts...)
+	sutTS := MustT(startCluster(ctx, cfg, opts...))
+
+	return pkg.NewSUT(MustT(openSystemDB(sutTS)), logger), sutTS
+}
 
-	url := sutTS.PGURL()
+// openSystemDB connects to the system database of ts, which is where the SUT
+// executes DDL from.
+func openSystemDB(ts testserver.TestServer) (*sqlx.DB, error) {
+	url := ts.PGURL()
 	url.Path = "system"
-	sutDB := MustT(sqlx.Open("pgx", url.String()))
-	return pkg.NewSUT(sutDB, logger)
+	db, err := sqlx.Open("pgx", url.String())
+	return db, errors.WithStack(err)
 }
 
 func NewOracle(ctx context.Context) pkg.System {
@@ -61,69 +69,110 @@ func NewOracle(ctx context.Context) pkg.System {
 	return MustT(pkg.NewOracle(oracleDB, logger))
 }
 
-func main() {
-	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
-	defer cancel()
-
-	sut := NewSUT(ctx)
-	oracle := NewOracle(ctx)
-	logger := log.Default()
-
-	seed := time.Now().UnixNano()
-	rand.Seed(seed)
+// cachedBinary returns the path of the cockroach-<version> binary within
+// cacheDir, if there is one.
+func cachedBinary(cacheDir, version string) (string, bool) {
+	if cacheDir == "" {
+		return "", false
+	}
+	path := filepath.Join(cacheDir, "cockroach-"+version)
+	_, err := os.Stat(path)
+	return path, err == nil
+}
 
-	iterations := 500
+// versionOpt returns a TestServerOpt that runs version, preferring a binary
+// from cacheDir to downloading one.
+func versionOpt(cacheDir, version string) testserver.TestServerOpt {
+	if path, ok := cachedBinary(cacheDir, version); ok {
+		return testserver.CockroachBinaryPathOpt(path)
+	}
+	return testserver.CustomVersionOpt(version)
+}
 
-	log.Printf("Iterations: %d, Seed: %d", iterations, seed)
+// binaryPath returns the path to a cockroach binary for version, from
+// cacheDir if possible or by downloading it otherwise.
+func binaryPath(cacheDir, version string) (string, error) {
+	if path, ok := cachedBinary(cacheDir, version); ok {
+		return path, nil
+	}
+	path, err := testserver.DownloadBinary(&testserver.TestConfig{}, version, false)
+	return path, errors.Wrapf(err, "downloading %s", version)
+}
 
-	state := MustT(oracle.State(ctx))
+// writeGraphs renders g as DOT, Mermaid and JSON into dir, highlighting the
+// provided nodes.
+func writeGraphs(dir, name string, g *dag.Graph, highlight []dag.INode) error {
+	opts := pkg.ExportOptions(highlight...)
 
-	defer func() {
-		ctx := context.Background()
+	encoded, err := json.MarshalIndent(g, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, name+".json"), encoded, 0o644); err != nil {
+		return err
+	}
 
-		state = MustT(oracle.State(ctx))
-		sutState := MustT(sut.State(ctx))
-		logger.Printf("\tSUT State: %s", sutState.String())
-		logger.Printf("\tOracle State: %s", state.String())
-	}()
+	if err := os.WriteFile(filepath.Join(dir, name+".dot"), []byte(g.DOT(opts)), 0o644); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, name+".mmd"), []byte(g.Mermaid(opts)), 0o644)
+}
 
-	for i := 0; i < iterations; i++ {
-		cmd := pkg.GenerateCommand(state)
+// loadProfile returns the profile named name from either the builtin profiles
+// or the profiles file at path, if provided.
+func loadProfile(name, path string) (*pkg.Profile, error) {
+	profiles := map[string]*pkg.Profile{}
+	for name, p := range pkg.BuiltinProfiles {
+		profiles[name] = p
+	}
 
-		logger.Printf("Step %d: %s", i, pkg.CommandToString(cmd))
+	if path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
 
-		if err := oracle.Execute(ctx, cmd); err != nil {
-			panic(err)
+		loaded, err := pkg.LoadProfiles(f)
+		if err != nil {
+			return nil, err
 		}
-		if err := sut.Execute(ctx, cmd); err != nil {
-			panic(err)
+		for _, p := range loaded {
+			profiles[p.Name] = p
 		}
+	}
 
-		state = MustT(oracle.State(ctx))
-		sutState := MustT(sut.State(ctx))
+	p, ok := profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown profile %q, expected one of %v", name, pkg.ProfileNames(profiles))
+	}
+	return p, nil
+}
 
-		opts := []cmp.Option{
-			// cmp.AllowUnexported(dag.Graph{}),
-			cmpopts.IgnoreTypes(dag.Node{}),
-			// cmpopts.IgnoreUnexported(dag.Node{}),
-			cmp.Transformer("Comparable", func(g *dag.Graph) []dag.CNode {
-				return g.Comparable()
-			}),
-		}
+// commands are the subcommands of scwl. The first argument selects the
+// command, if it's omitted or is a flag, run is assumed.
+var commands = map[string]func(ctx context.Context, args []string) error{
+	"run":              run,
+	"export-test":      exportTest,
+	"export-logictest": exportLogicTest,
+	"bisect":           bisect,
+}
 
-		// x := cmp.Transformer("graph", func(g *dag.Graph) []dag.CNode {
-		// 	return g.Comparable()
-		// 	// return dag.All[dag.INode](g)
-		// })
-
-		if diff := cmp.Diff(
-			state, sutState, opts...,
-		); diff != "" {
-			// log.Printf("oracle: %s", MustT(json.MarshalIndent(state.Comparable(), "", "\t")))
-			// log.Printf("sut: %s", MustT(json.MarshalIndent(sutState.Comparable(), "", "\t")))
-			logger.Printf("\tSUT State: %s", sutState.String())
-			logger.Printf("\tOracle State: %s", state.String())
-			log.Fatalf("State Mismatch!\n%s", diff)
-		}
+func main() {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	name, args := "run", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+
+	command, ok := commands[name]
+	if !ok {
+		log.Fatalf("unknown command %q", name)
+	}
+
+	if err := command(ctx, args); err != nil {
+		log.Fatalf("%+v", err)
 	}
 }
@@ -0,0 +1,73 @@
+package pkg
+
+import (
+	"math/rand"
+)
+
+// aliasTable samples from a discrete distribution in constant time using
+// Vose's variant of the alias method.
+// https://en.wikipedia.org/wiki/Alias_method
+type aliasTable struct {
+	prob  []float64
+	alias []int
+}
+
+// newAliasTable constructs an aliasTable where the probability of sampling i
+// is weights[i] / sum(weights). weights must be non-negative with a positive
+// sum.
+func newAliasTable(weights []int) *aliasTable {
+	n := len(weights)
+	total := 0
+	for _, w := range weights {
+		total += w
+	}
+
+	a := &aliasTable{
+		prob:  make([]float64, n),
+		alias: make([]int, n),
+	}
+
+	scaled := make([]float64, n)
+	var small, large []int
+	for i, w := range weights {
+		scaled[i] = float64(w*n) / float64(total)
+		if scaled[i] < 1 {
+			small = append(small, i)
+		} else {
+			large = append(large, i)
+		}
+	}
+
+	for len(small) > 0 && len(large) > 0 {
+		s, l := small[len(small)-1], large[len(large)-1]
+		small, large = small[:len(small)-1], large[:len(large)-1]
+
+		a.prob[s] = scaled[s]
+		a.alias[s] = l
+
+		scaled[l] = (scaled[l] + scaled[s]) - 1
+		if scaled[l] < 1 {
+			small = append(small, l)
+		} else {
+			large = append(large, l)
+		}
+	}
+
+	// Anything remaining is only due to floating point imprecision and should
+	// be treated as having a probability of 1.
+	for _, i := range append(small, large...) {
+		a.prob[i] = 1
+	}
+
+	return a
+}
+
+// Sample returns a random index, weighted by the weights the table was
+// constructed with.
+func (a *aliasTable) Sample() int {
+	i := rand.Intn(len(a.prob))
+	if rand.Float64() < a.prob[i] {
+		return i
+	}
+	return a.alias[i]
+}
@@ -0,0 +1,74 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/chrisseto/scwl/pkg/dag"
+	"github.com/cockroachdb/errors"
+)
+
+// BackupRestorer is implemented by Systems that can back up and restore
+// databases.
+type BackupRestorer interface {
+	// BackupRestore backs up the database name, restores it under a new name
+	// and returns an error if either fails or the restored state differs from
+	// the original.
+	BackupRestore(ctx context.Context, name string) error
+}
+
+// BackupRestore implements [BackupRestorer]. Backups are written to
+// nodelocal storage, so the SUT must have been started with an external IO
+// directory. The restored database is dropped afterwards.
+func (o *sut) BackupRestore(ctx context.Context, name string) (err error) {
+	original, err := o.State(ctx)
+	if err != nil {
+		return err
+	}
+
+	databases := dag.Nodes[*Database](original)
+	taken := make([]string, len(databases))
+	for i, db := range databases {
+		taken[i] = db.Name
+	}
+
+	restored := RandomName(false, taken...)
+	dest := "nodelocal://1/" + RandomString("backup")
+
+	backup := fmt.Sprintf(`BACKUP DATABASE %s INTO %s`, QuoteIdentifier(name), QuoteLiteral(dest))
+	o.log.Printf("Running: %q", backup)
+	if _, err := o.conn.ExecContext(ctx, backup); err != nil {
+		return errors.Wrapf(err, "backing up %s", name)
+	}
+
+	restore := fmt.Sprintf(`RESTORE DATABASE %s FROM LATEST IN %s WITH new_db_name = %s`, QuoteIdentifier(name), QuoteLiteral(dest), QuoteLiteral(restored))
+	o.log.Printf("Running: %q", restore)
+	if _, err := o.conn.ExecContext(ctx, restore); err != nil {
+		return errors.Wrapf(err, "restoring %s as %s", name, restored)
+	}
+
+	defer func() {
+		if _, dropErr := o.conn.ExecContext(ctx, `DROP DATABASE IF EXISTS `+QuoteIdentifier(restored)+` CASCADE`); dropErr != nil && err == nil {
+			err = errors.Wrapf(dropErr, "dropping %s", restored)
+		}
+		if settleErr := o.Settle(ctx); settleErr != nil && err == nil {
+			err = settleErr
+		}
+	}()
+
+	if err := o.Settle(ctx); err != nil {
+		return err
+	}
+
+	state, err := o.State(ctx)
+	if err != nil {
+		return err
+	}
+
+	disagreements := describeMismatched(Mismatched(DatabaseState(original, name, name), DatabaseState(state, restored, name)))
+	if len(disagreements) > 0 {
+		return errors.Newf("%s restored as %s differs from the original:\n\t%s", name, restored, strings.Join(disagreements, "\n\t"))
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package pkg
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// Bisect returns the index of the first of n ordered targets for which fails
+// returns true. Targets are assumed to pass up until some index and then
+// fail for all following indexes. If no target fails, n is returned. The last
+// passing target, if any, is the one prior to the returned index.
+func Bisect(n int, fails func(i int) (bool, error)) (int, error) {
+	if n == 0 {
+		return 0, errors.New("nothing to bisect")
+	}
+
+	// Check the endpoints before searching to avoid reporting a bogus
+	// transition if the reproduction fails everywhere or nowhere.
+	if failed, err := fails(n - 1); err != nil || !failed {
+		return n, err
+	}
+	if n == 1 {
+		return 0, nil
+	}
+	if failed, err := fails(0); err != nil || failed {
+		return 0, err
+	}
+
+	// Invariant: lo passes, hi fails.
+	lo, hi := 0, n-1
+	for hi-lo > 1 {
+		mid := lo + (hi-lo)/2
+		failed, err := fails(mid)
+		if err != nil {
+			return 0, err
+		}
+		if failed {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+
+	return hi, nil
+}
+
+// ExpandVersions parses a comma separated list of CockroachDB versions.
+// Elements may be a range of patch releases within a single major version,
+// eg: v23.1.0..v23.1.3 expands to v23.1.0, v23.1.1, v23.1.2, v23.1.3.
+func ExpandVersions(spec string) ([]string, error) {
+	var versions []string
+	for _, elem := range strings.Split(spec, ",") {
+		elem = strings.TrimSpace(elem)
+		if elem == "" {
+			continue
+		}
+
+		start, end, ok := strings.Cut(elem, "..")
+		if !ok {
+			versions = append(versions, elem)
+			continue
+		}
+
+		var major, minor, from, endMajor, endMinor, to int
+		if _, err := fmt.Sscanf(start, "v%d.%d.%d", &major, &minor, &from); err != nil {
+			return nil, errors.Wrapf(err, "parsing %q", start)
+		}
+		if _, err := fmt.Sscanf(end, "v%d.%d.%d", &endMajor, &endMinor, &to); err != nil {
+			return nil, errors.Wrapf(err, "parsing %q", end)
+		}
+
+		if major != endMajor || minor != endMinor {
+			return nil, errors.Newf("range %q must be within a single major version", elem)
+		}
+		if from > to {
+			return nil, errors.Newf("range %q is empty", elem)
+		}
+
+		for patch := from; patch <= to; patch++ {
+			versions = append(versions, fmt.Sprintf("v%d.%d.%d", major, minor, patch))
+		}
+	}
+
+	return versions, nil
+}
@@ -0,0 +1,37 @@
+package pkg_test
+
+import (
+	"testing"
+
+	"github.com/chrisseto/scwl/pkg"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBisect(t *testing.T) {
+	for _, tc := range []struct {
+		n, firstFailing int
+	}{
+		{1, 0}, {1, 1}, {2, 0}, {2, 1}, {2, 2}, {7, 0}, {7, 3}, {7, 6}, {7, 7}, {100, 42},
+	} {
+		var checked []int
+		idx, err := pkg.Bisect(tc.n, func(i int) (bool, error) {
+			require.NotContains(t, checked, i, "checked %d twice", i)
+			checked = append(checked, i)
+			return i >= tc.firstFailing, nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, tc.firstFailing, idx, "n=%d", tc.n)
+	}
+
+	_, err := pkg.Bisect(0, nil)
+	require.Error(t, err)
+}
+
+func TestExpandVersions(t *testing.T) {
+	versions, err := pkg.ExpandVersions("v22.2.14, v23.1.0..v23.1.2,v23.2.0-beta.1")
+	require.NoError(t, err)
+	require.Equal(t, []string{"v22.2.14", "v23.1.0", "v23.1.1", "v23.1.2", "v23.2.0-beta.1"}, versions)
+
+	_, err = pkg.ExpandVersions("v23.1.0..v23.2.0")
+	require.EqualError(t, err, `range "v23.1.0..v23.2.0" must be within a single major version`)
+}
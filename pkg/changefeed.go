@@ -0,0 +1,235 @@
+package pkg
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chrisseto/scwl/pkg/dag"
+	"github.com/cockroachdb/errors"
+)
+
+// ChangefeedEvent is how a changefeed watching a table reacts to a Command.
+type ChangefeedEvent string
+
+const (
+	// ChangefeedEventNone indicates that the changefeed continued past the
+	// Command.
+	ChangefeedEventNone ChangefeedEvent = "none"
+	// ChangefeedEventSchemaChange indicates that the changefeed stopped due
+	// to a schema change that added or dropped columns, which would
+	// otherwise require a backfill.
+	ChangefeedEventSchemaChange ChangefeedEvent = "schema change"
+	// ChangefeedEventDropped indicates that the changefeed failed as its
+	// table was dropped.
+	ChangefeedEventDropped ChangefeedEvent = "dropped"
+)
+
+// ChangefeedWatcher is implemented by Systems that can watch the table
+// affected by a Command with a changefeed.
+type ChangefeedWatcher interface {
+	// ExecuteWatched executes a Command, as Execute does, while a changefeed
+	// watches the table that it affects. event is how the changefeed reacted.
+	// execErr is the result of executing the Command while err indicates
+	// that the changefeed couldn't be observed.
+	ExecuteWatched(context.Context, Command) (event ChangefeedEvent, execErr, err error)
+}
+
+// ExpectedChangefeedEvent returns how a changefeed on the table affected by
+// cmd, as found by [AffectedTable], should react to it given after, the
+// state once cmd has been applied. Changefeeds are expected to stop if the
+// table's columns change and fail if it's dropped.
+func ExpectedChangefeedEvent(cmd Command, after *dag.Graph) ChangefeedEvent {
+	table := AffectedTable(cmd)
+	if table == nil {
+		return ChangefeedEventNone
+	}
+
+	name := FullyQualifiedName(table)
+	if rename, ok := cmd.(RenameTable); ok {
+		name = FullyQualifiedName(table.Schema()) + "." + rename.Name
+	}
+
+	renamed, err := dag.Nodes[*Table](after, WithFullQualifiedName[*Table](name)).TryOne()
+	if err != nil {
+		return ChangefeedEventDropped
+	}
+
+	columns := func(t *Table) map[string]bool {
+		out := map[string]bool{}
+		for _, c := range t.Columns() {
+			out[c.Name] = true
+		}
+		return out
+	}
+
+	beforeColumns, afterColumns := columns(table), columns(renamed)
+	if len(beforeColumns) != len(afterColumns) {
+		return ChangefeedEventSchemaChange
+	}
+	for name := range beforeColumns {
+		if !afterColumns[name] {
+			return ChangefeedEventSchemaChange
+		}
+	}
+	return ChangefeedEventNone
+}
+
+// changefeedTimeout bounds how long ExecuteWatched waits for a changefeed to
+// either stop or resolve past a Command.
+const changefeedTimeout = time.Minute
+
+// ExecuteWatched implements [ChangefeedWatcher] with a sinkless changefeed,
+// which requires kv.rangefeed.enabled. Only changes to columns are reported
+// as schema change events and tables are empty, so the changefeed only emits
+// resolved timestamps until it stops. Commands that don't affect a table are
+// executed without a changefeed.
+func (o *sut) ExecuteWatched(ctx context.Context, cmd Command) (event ChangefeedEvent, execErr, err error) {
+	table := AffectedTable(cmd)
+	if table == nil {
+		return ChangefeedEventNone, o.Execute(ctx, cmd), nil
+	}
+
+	feedCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stmt := `EXPERIMENTAL CHANGEFEED FOR ` + QuotedName(table) + ` WITH schema_change_policy = 'stop', schema_change_events = 'column_changes', resolved = '100ms', no_initial_scan`
+	o.log.Printf("Watching: %q", stmt)
+
+	rows, err := o.conn.QueryContext(feedCtx, stmt)
+	if err != nil {
+		return "", nil, errors.WithStack(err)
+	}
+
+	// The first resolved timestamp indicates that the changefeed is running,
+	// so it will observe cmd.
+	if _, err := nextResolved(rows); err != nil {
+		rows.Close()
+		return "", nil, errors.Wrap(err, "starting changefeed")
+	}
+
+	var mu sync.Mutex
+	var resolved string
+	done := make(chan error, 1)
+	go func() {
+		defer rows.Close()
+		for {
+			ts, err := nextResolved(rows)
+			if err != nil {
+				done <- err
+				return
+			}
+			mu.Lock()
+			resolved = ts
+			mu.Unlock()
+		}
+	}()
+
+	execErr = o.Execute(ctx, cmd)
+
+	if err := o.Settle(ctx); err != nil {
+		return "", execErr, err
+	}
+
+	var now string
+	if err := o.conn.GetContext(ctx, &now, `SELECT cluster_logical_timestamp()::STRING`); err != nil {
+		return "", execErr, errors.WithStack(err)
+	}
+
+	timeout := time.After(changefeedTimeout)
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case err := <-done:
+			event, err := classifyChangefeedError(err, table)
+			return event, execErr, err
+
+		case <-ticker.C:
+			mu.Lock()
+			ts := resolved
+			mu.Unlock()
+
+			if ts != "" && !hlcLess(ts, now) {
+				cancel()
+				<-done
+				return ChangefeedEventNone, execErr, nil
+			}
+
+		case <-timeout:
+			return "", execErr, errors.Newf("changefeed neither stopped nor resolved past %s within %s", now, changefeedTimeout)
+		}
+	}
+}
+
+// nextResolved returns the next resolved timestamp emitted by a sinkless
+// changefeed. Rows other than resolved timestamps are skipped. The error that
+// the changefeed stopped with is returned once it has.
+func nextResolved(rows *sql.Rows) (string, error) {
+	for rows.Next() {
+		var table, key sql.NullString
+		var value []byte
+		if err := rows.Scan(&table, &key, &value); err != nil {
+			return "", errors.WithStack(err)
+		}
+
+		// Resolved timestamps have no table or key.
+		if table.Valid {
+			continue
+		}
+
+		var msg struct {
+			Resolved string `json:"resolved"`
+		}
+		if err := json.Unmarshal(value, &msg); err != nil {
+			return "", errors.WithStack(err)
+		}
+		return msg.Resolved, nil
+	}
+
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	return "", errors.New("changefeed ended without an error")
+}
+
+// classifyChangefeedError returns the event that caused a changefeed on table
+// to stop with err. err is returned if it isn't due to table.
+//
+// CockroachDB doesn't give these errors distinct codes, so they're matched by
+// their messages as of v23.1, the default -version. Other versions may word
+// them differently, which surfaces as a "Changefeed Failure!" rather than a
+// misclassified event.
+func classifyChangefeedError(err error, table *Table) (ChangefeedEvent, error) {
+	switch msg := err.Error(); {
+	case strings.Contains(msg, "schema change occurred"):
+		return ChangefeedEventSchemaChange, nil
+	// Dropped tables are reported as `"<name>" was dropped`, which is matched
+	// precisely so that unrelated failures aren't mistaken for it.
+	case strings.Contains(msg, `"`+table.Name+`" was dropped`):
+		return ChangefeedEventDropped, nil
+	default:
+		return "", errors.Wrap(err, "changefeed failed")
+	}
+}
+
+// hlcLess reports whether the HLC timestamp a, formatted as a decimal of
+// wall time and logical ticks as returned by cluster_logical_timestamp(), is
+// before b. Malformed timestamps are considered to be zero.
+func hlcLess(a, b string) bool {
+	parse := func(s string) (wall, logical int64) {
+		wallStr, logicalStr, _ := strings.Cut(s, ".")
+		wall, _ = strconv.ParseInt(wallStr, 10, 64)
+		logical, _ = strconv.ParseInt(logicalStr, 10, 64)
+		return wall, logical
+	}
+
+	aWall, aLogical := parse(a)
+	bWall, bLogical := parse(b)
+	return aWall < bWall || aWall == bWall && aLogical < bLogical
+}
@@ -0,0 +1,30 @@
+package pkg_test
+
+import (
+	"testing"
+
+	"github.com/chrisseto/scwl/pkg"
+	"github.com/chrisseto/scwl/pkg/dag"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpectedChangefeedEvent(t *testing.T) {
+	before := publicState("users", "users.id", "users.email")
+	users := pkg.ByFQN[*pkg.Table](before, "defaultdb.public.users")
+	email := pkg.ByFQN[*pkg.Column](before, "defaultdb.public.users.cols.email")
+
+	for _, tc := range []struct {
+		cmd   pkg.Command
+		after *dag.Graph
+		want  pkg.ChangefeedEvent
+	}{
+		{pkg.AddColumn{Table: users, Name: "name"}, publicState("users", "users.id", "users.email", "users.name"), pkg.ChangefeedEventSchemaChange},
+		{pkg.DropColumn{Column: email}, publicState("users", "users.id"), pkg.ChangefeedEventSchemaChange},
+		{pkg.CreateIndex{Table: users, Columns: []*pkg.Column{email}, Name: "idx"}, publicState("users", "users.id", "users.email"), pkg.ChangefeedEventNone},
+		{pkg.RenameTable{Table: users, Name: "people"}, publicState("people", "people.id", "people.email"), pkg.ChangefeedEventNone},
+		{pkg.DropTable{Table: users}, publicState(), pkg.ChangefeedEventDropped},
+		{pkg.CreateTable{Schema: users.Schema(), Name: "posts"}, publicState("users", "users.id", "users.email"), pkg.ChangefeedEventNone},
+	} {
+		require.Equal(t, tc.want, pkg.ExpectedChangefeedEvent(tc.cmd, tc.after), pkg.CommandToString(tc.cmd))
+	}
+}
@@ -0,0 +1,244 @@
+package pkg
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+
+	"github.com/chrisseto/scwl/pkg/dag"
+)
+
+// shape is a named predicate over a Command, describing the state that the
+// Command interacts with. eg: "table has an incoming foreign key".
+type shape struct {
+	name  string
+	match func(Command) bool
+}
+
+// shapes lists the interesting shapes for each Command type. Every Command
+// type is additionally covered by an unnamed base shape that matches
+// anything.
+var shapes = map[reflect.Type][]shape{
+	reflect.TypeOf(CreateSchema{}): databaseShapes(func(c Command) *Database { return c.(CreateSchema).Database }),
+	reflect.TypeOf(CreateTable{}): {
+		{"in non-public schema", func(c Command) bool { return NotPublic(c.(CreateTable).Schema) }},
+	},
+	reflect.TypeOf(RenameDatabase{}): databaseShapes(func(c Command) *Database { return c.(RenameDatabase).Database }),
+	reflect.TypeOf(DropDatabase{}):   databaseShapes(func(c Command) *Database { return c.(DropDatabase).Database }),
+	reflect.TypeOf(RenameSchema{}):   schemaShapes(func(c Command) *Schema { return c.(RenameSchema).Schema }),
+	reflect.TypeOf(DropSchema{}):     schemaShapes(func(c Command) *Schema { return c.(DropSchema).Schema }),
+	reflect.TypeOf(RenameTable{}):    tableShapes(func(c Command) *Table { return c.(RenameTable).Table }),
+	reflect.TypeOf(DropTable{}):      tableShapes(func(c Command) *Table { return c.(DropTable).Table }),
+	reflect.TypeOf(AddColumn{}):      tableShapes(func(c Command) *Table { return c.(AddColumn).Table }),
+	reflect.TypeOf(DropColumn{}): {
+		{"indexed column", func(c Command) bool { return len(indexesOf(c.(DropColumn).Column)) > 0 }},
+		{"column referenced by foreign key", func(c Command) bool { return len(referencedBy(c.(DropColumn).Column)) > 0 }},
+		{"column referencing foreign key", func(c Command) bool { return len(references(c.(DropColumn).Column)) > 0 }},
+	},
+	reflect.TypeOf(CreateIndex{}): {
+		{"unique", func(c Command) bool { return c.(CreateIndex).Unique }},
+		{"multi-column", func(c Command) bool { return len(c.(CreateIndex).Columns) > 1 }},
+		{"on foreign key column", func(c Command) bool {
+			for _, col := range c.(CreateIndex).Columns {
+				if len(referencedBy(col))+len(references(col)) > 0 {
+					return true
+				}
+			}
+			return false
+		}},
+	},
+	reflect.TypeOf(DropIndex{}): {
+		{"unique", func(c Command) bool { return c.(DropIndex).Index.Unique }},
+		{"multi-column", func(c Command) bool { return len(c.(DropIndex).Index.Columns()) > 1 }},
+		{"used by unique foreign key target", func(c Command) bool {
+			idx := c.(DropIndex).Index
+			return idx.Unique && len(idx.Columns()) == 1 && len(referencedBy(idx.Columns()[0])) > 0
+		}},
+	},
+	reflect.TypeOf(CreateForeignKeyConstraint{}): {
+		{"cross-schema", func(c Command) bool {
+			fk := c.(CreateForeignKeyConstraint)
+			return fk.From.Table().Schema() != fk.To.Table().Schema()
+		}},
+		{"chained with existing foreign key", func(c Command) bool {
+			fk := c.(CreateForeignKeyConstraint)
+			return len(references(fk.To)) > 0 || len(referencedBy(fk.From)) > 0
+		}},
+		{"indexed origin column", func(c Command) bool { return len(indexesOf(c.(CreateForeignKeyConstraint).From)) > 0 }},
+	},
+}
+
+func databaseShapes(database func(Command) *Database) []shape {
+	return []shape{
+		{"database with user schemas", func(c Command) bool { return len(dag.Outgoing[*Schema](database(c), NotPublic)) > 0 }},
+		{"database with tables", func(c Command) bool { return len(dag.Descendants[*Table](database(c))) > 0 }},
+		{"database with foreign keys", func(c Command) bool {
+			for _, col := range dag.Descendants[*Column](database(c)) {
+				if len(references(col)) > 0 {
+					return true
+				}
+			}
+			return false
+		}},
+	}
+}
+
+func schemaShapes(schema func(Command) *Schema) []shape {
+	return []shape{
+		{"schema with tables", func(c Command) bool { return len(schema(c).Tables()) > 0 }},
+		{"schema with foreign key from another schema", func(c Command) bool {
+			s := schema(c)
+			for _, col := range dag.Descendants[*Column](s) {
+				for _, fk := range referencedBy(col) {
+					if fk.From().Table().Schema() != s {
+						return true
+					}
+				}
+			}
+			return false
+		}},
+	}
+}
+
+func tableShapes(table func(Command) *Table) []shape {
+	return []shape{
+		{"table with indexes", func(c Command) bool { return len(table(c).Indexes()) > 0 }},
+		{"table with incoming foreign key", func(c Command) bool {
+			for _, col := range table(c).Columns() {
+				if len(referencedBy(col)) > 0 {
+					return true
+				}
+			}
+			return false
+		}},
+		{"table with outgoing foreign key", func(c Command) bool {
+			for _, col := range table(c).Columns() {
+				if len(references(col)) > 0 {
+					return true
+				}
+			}
+			return false
+		}},
+		{"table in non-public schema", func(c Command) bool { return NotPublic(table(c).Schema()) }},
+	}
+}
+
+// indexesOf returns the indexes that contain c.
+func indexesOf(c *Column) []*Index {
+	return dag.Incoming[*Index](c)
+}
+
+// referencedBy returns the foreign key constraints that reference c.
+func referencedBy(c *Column) []*ForeignKeyConstraint {
+	return dag.Incoming[*ForeignKeyConstraint](c, func(fk *ForeignKeyConstraint) bool { return fk.To() == c })
+}
+
+// references returns the foreign key constraints that originate from c.
+func references(c *Column) []*ForeignKeyConstraint {
+	return dag.Incoming[*ForeignKeyConstraint](c, func(fk *ForeignKeyConstraint) bool { return fk.From() == c })
+}
+
+// Coverage tracks which (Command, shape) pairs have been exercised over the
+// course of a workload. It may be used to steer generation towards
+// unexercised pairs.
+type Coverage struct {
+	counts map[string]int
+}
+
+func NewCoverage() *Coverage {
+	return &Coverage{counts: map[string]int{}}
+}
+
+// pairs returns the (Command, shape) pairs that cmd exercises.
+func pairs(cmd Command) []string {
+	t := reflect.TypeOf(cmd)
+	out := []string{t.Name()}
+	for _, s := range shapes[t] {
+		if s.match(cmd) {
+			out = append(out, fmt.Sprintf("%s[%s]", t.Name(), s.name))
+		}
+	}
+	return out
+}
+
+// Record marks the pairs exercised by cmd as covered. cmd must still be
+// attached to the state it was generated from.
+func (c *Coverage) Record(cmd Command) {
+	for _, pair := range pairs(cmd) {
+		c.counts[pair]++
+	}
+}
+
+// novelty scores cmd by how rarely the pairs it exercises have been covered.
+func (c *Coverage) novelty(cmd Command) float64 {
+	var score float64
+	for _, pair := range pairs(cmd) {
+		score += 1 / float64(1+c.counts[pair])
+	}
+	return score
+}
+
+// Generate produces candidates Commands with [GenerateCommand] and returns
+// the one that exercises the least covered pairs. A candidates value
+// of 1 is equivalent to calling GenerateCommand directly.
+func (c *Coverage) Generate(p *Profile, g *dag.Graph, candidates int) (Command, error) {
+	var best Command
+	var bestScore float64
+
+	for i := 0; i < candidates || best == nil; i++ {
+		cmd, err := GenerateCommand(p, g)
+		if err != nil {
+			return nil, err
+		}
+
+		if score := c.novelty(cmd); best == nil || score > bestScore {
+			best, bestScore = cmd, score
+		}
+	}
+
+	return best, nil
+}
+
+// Report writes a summary of the exercised pairs to w, along with the pairs
+// that were possible under p but never exercised.
+func (c *Coverage) Report(w io.Writer, p *Profile) {
+	var possible []string
+	for _, cmd := range AllCommands {
+		if p.Weight(cmd) <= 0 {
+			continue
+		}
+		t := reflect.TypeOf(cmd)
+		possible = append(possible, t.Name())
+		for _, s := range shapes[t] {
+			possible = append(possible, fmt.Sprintf("%s[%s]", t.Name(), s.name))
+		}
+	}
+
+	covered := 0
+	var missing []string
+	for _, pair := range possible {
+		if c.counts[pair] > 0 {
+			covered++
+		} else {
+			missing = append(missing, pair)
+		}
+	}
+
+	exercised := make([]string, 0, len(c.counts))
+	for pair := range c.counts {
+		exercised = append(exercised, pair)
+	}
+	sort.Strings(exercised)
+
+	fmt.Fprintf(w, "Coverage: %d/%d pairs exercised\n", covered, len(possible))
+	for _, pair := range exercised {
+		fmt.Fprintf(w, "\t%6d %s\n", c.counts[pair], pair)
+	}
+	if len(missing) > 0 {
+		fmt.Fprintf(w, "Never exercised:\n")
+		for _, pair := range missing {
+			fmt.Fprintf(w, "\t%s\n", pair)
+		}
+	}
+}
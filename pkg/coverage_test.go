@@ -0,0 +1,62 @@
+package pkg_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/chrisseto/scwl/pkg"
+	"github.com/chrisseto/scwl/pkg/dag"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoverage(t *testing.T) {
+	g := dag.New()
+
+	defaultdb := g.AddNode("1", &pkg.Database{Name: "defaultdb"}).(*pkg.Database)
+	public := g.AddNode("2", &pkg.Schema{Name: "public"}).(*pkg.Schema)
+	users := g.AddNode("3", &pkg.Table{Name: "users"}).(*pkg.Table)
+	id := g.AddNode("4", &pkg.Column{Name: "id"}).(*pkg.Column)
+	idx := g.AddNode("5", &pkg.Index{Name: "users_id", Unique: true}).(*pkg.Index)
+	posts := g.AddNode("6", &pkg.Table{Name: "posts"}).(*pkg.Table)
+	author := g.AddNode("7", &pkg.Column{Name: "author"}).(*pkg.Column)
+	fk := g.AddNode("8", &pkg.ForeignKeyConstraint{Name: "fk_author"}).(*pkg.ForeignKeyConstraint)
+
+	g.AddEdge(defaultdb, public)
+	g.AddEdge(public, users)
+	g.AddEdge(public, posts)
+	g.AddEdge(users, id)
+	g.AddEdge(users, idx)
+	g.AddEdge(idx, id)
+	g.AddEdge(posts, author)
+	g.AddEdge(fk, id)
+	g.AddEdge(fk, author)
+
+	profile := &pkg.Profile{Weights: map[string]int{}}
+	for _, cmd := range pkg.AllCommands {
+		profile.Weights[reflect.TypeOf(cmd).Name()] = 0
+	}
+	profile.Weights["RenameTable"] = 1
+	profile.Weights["DropIndex"] = 1
+
+	coverage := pkg.NewCoverage()
+	coverage.Record(pkg.RenameTable{Table: users, Name: "people"})
+	coverage.Record(pkg.RenameTable{Table: posts, Name: "articles"})
+	coverage.Record(pkg.DropIndex{Index: idx})
+
+	var b strings.Builder
+	coverage.Report(&b, profile)
+
+	require.Equal(t, `Coverage: 7/9 pairs exercised
+	     1 DropIndex
+	     1 DropIndex[unique]
+	     1 DropIndex[used by unique foreign key target]
+	     2 RenameTable
+	     1 RenameTable[table with incoming foreign key]
+	     1 RenameTable[table with indexes]
+	     1 RenameTable[table with outgoing foreign key]
+Never exercised:
+	DropIndex[multi-column]
+	RenameTable[table in non-public schema]
+`, b.String())
+}
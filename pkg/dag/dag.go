@@ -3,7 +3,6 @@ package dag
 import (
 	"bytes"
 	"fmt"
-	"reflect"
 )
 
 type INode interface {
@@ -36,25 +35,22 @@ func (n *Node) setGraph(g *Graph) {
 	}
 }
 
-func New(clone func(INode) INode) *Graph {
+// New returns an empty Graph. Node types added to it should be [Register]ed
+// for the graph to be cloned or serialized.
+func New() *Graph {
 	return &Graph{
-		clone: func(i INode) INode {
-			c := clone(i)
-			c.setGraph(nil)
-			return c
-		},
 		nodesByID: make(map[string]INode),
+		idsByNode: make(map[INode]string),
 		outgoing:  make(map[INode][]INode),
 		incoming:  make(map[INode][]INode),
 	}
 }
 
 type Graph struct {
-	clone func(INode) INode
-
 	// TODO add RWMutext
 	nodes     []INode
 	nodesByID map[string]INode
+	idsByNode map[INode]string
 	outgoing  map[INode][]INode
 	incoming  map[INode][]INode
 }
@@ -63,10 +59,16 @@ func (g *Graph) ByID(id string) INode {
 	return g.nodesByID[id]
 }
 
+// ID returns the ID that n was added to the graph with.
+func (g *Graph) ID(n INode) string {
+	return g.idsByNode[n]
+}
+
 func (g *Graph) AddNode(id string, n INode) INode {
 	n.setGraph(g)
 	g.nodes = append(g.nodes, n)
 	g.nodesByID[id] = n
+	g.idsByNode[n] = id
 	return n
 }
 
@@ -82,20 +84,7 @@ func (g *Graph) String() string {
 	nodes := make(map[INode]int, len(g.nodes))
 	for i, n := range g.nodes {
 		nodes[n] = i
-		v := reflect.ValueOf(n).Elem()
-		t := v.Type()
-		fmt.Fprintf(&b, "%d: %s{", i, t.Name())
-		for j := 0; j < t.NumField(); j++ {
-			f := t.Field(j)
-			if f.Anonymous {
-				continue
-			}
-			if j > 1 {
-				fmt.Fprint(&b, ", ")
-			}
-			fmt.Fprintf(&b, "%s: %v", f.Name, v.Field(j))
-		}
-		fmt.Fprint(&b, "}\n")
+		fmt.Fprintf(&b, "%d: %s\n", i, Describe(n))
 	}
 
 	for _, from := range g.nodes {
@@ -129,7 +118,7 @@ func (g *Graph) Comparable() []CNode {
 	nodeToClone := make(map[INode]INode, len(g.nodes))
 
 	for i := range g.nodes {
-		nodes[i] = g.clone(g.nodes[i])
+		nodes[i] = Clone(g.nodes[i])
 		nodeToIndex[nodes[i]] = i
 		cloneToNode[nodes[i]] = g.nodes[i]
 		nodeToClone[g.nodes[i]] = nodes[i]
@@ -139,14 +128,15 @@ func (g *Graph) Comparable() []CNode {
 	for i := range nodes {
 		n := nodes[i]
 
-		outgoing := make([]INode, len(g.outgoing[n]))
-		for j, out := range g.outgoing[n] {
-			outgoing[j] = out
+		// Edges are keyed by the original nodes, not their clones.
+		outgoing := make([]INode, len(g.outgoing[g.nodes[i]]))
+		for j, out := range g.outgoing[g.nodes[i]] {
+			outgoing[j] = nodeToClone[out]
 		}
 
-		incoming := make([]INode, len(g.incoming[n]))
-		for j, in := range g.incoming[n] {
-			incoming[j] = in
+		incoming := make([]INode, len(g.incoming[g.nodes[i]]))
+		for j, in := range g.incoming[g.nodes[i]] {
+			incoming[j] = nodeToClone[in]
 		}
 
 		cnodes[i] = CNode{
@@ -1,6 +1,7 @@
 package dag_test
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/chrisseto/scwl/pkg/dag"
@@ -8,6 +9,14 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+func init() {
+	dag.Register[Person]()
+	dag.Register[Cat]()
+	dag.Register[Dog]()
+	dag.Register[Node]()
+	dag.Register[dag.Node]()
+}
+
 type Person struct {
 	dag.Node
 	Name string
@@ -23,8 +32,14 @@ type Dog struct {
 	Name string
 }
 
+// Node shares its name with dag.Node, which must not prevent either from
+// being registered.
+type Node struct {
+	dag.Node
+}
+
 func TestDag(t *testing.T) {
-	g := dag.New(nil)
+	g := dag.New()
 
 	bob := &Person{Name: "bob"}
 	june := &Cat{Name: "june"}
@@ -50,8 +65,207 @@ func TestDag(t *testing.T) {
 	require.Equal(t, []dag.INode(nil), dag.Outgoing[dag.INode](june).All())
 }
 
+func TestResult(t *testing.T) {
+	bob := &Person{Name: "bob"}
+	alice := &Person{Name: "alice"}
+	carol := &Person{Name: "carol"}
+
+	people := dag.Result[*Person]{bob, alice, carol}
+	isNot := func(p *Person) dag.Filter[*Person] {
+		return func(o *Person) bool { return o != p }
+	}
+
+	require.Equal(t, 3, people.Count())
+	require.Equal(t, bob, people.First())
+	require.Equal(t, dag.Result[*Person]{alice, carol}, people.Filter(isNot(bob)))
+	require.Equal(t, carol, people.Filter(isNot(bob), isNot(alice)).One())
+	require.ElementsMatch(t, people, people.Shuffle())
+
+	// Every element must be pickable, not just the first n.
+	seen := map[*Person]bool{}
+	for i := 0; i < 100; i++ {
+		for _, p := range people.Pick(1) {
+			seen[p] = true
+		}
+		require.Len(t, people.PickBetween(2, 2), 2)
+		n := len(people.PickUpTo(5))
+		require.True(t, n >= 1 && n <= 3)
+		require.Equal(t, alice, people.Weighted(func(p *Person) int {
+			if p == alice {
+				return 1
+			}
+			return 0
+		}))
+	}
+	require.Len(t, seen, 3)
+
+	_, err := people.TryOne()
+	require.True(t, errors.Is(err, dag.ErrMultipleResults))
+
+	_, err = dag.Result[*Person](nil).TryAny()
+	require.True(t, errors.Is(err, dag.ErrNoResults))
+
+	require.Panics(t, func() { dag.Result[*Person](nil).One() })
+}
+
+func TestTraversal(t *testing.T) {
+	g := dag.New()
+
+	bob := &Person{Name: "bob"}
+	june := &Cat{Name: "june"}
+	alice := &Person{Name: "alice"}
+	rex := &Dog{Name: "rex"}
+	carol := &Person{Name: "carol"}
+
+	g.AddNode("bob", bob)
+	g.AddNode("june", june)
+	g.AddNode("alice", alice)
+	g.AddNode("rex", rex)
+	g.AddNode("carol", carol)
+	g.AddEdge(bob, alice)
+	g.AddEdge(alice, june)
+	g.AddEdge(bob, rex)
+	g.AddEdge(rex, june)
+
+	require.Equal(t, []dag.INode{alice, rex, june}, dag.Descendants[dag.INode](bob).All())
+	require.Equal(t, []*Person{alice}, dag.Descendants[*Person](bob).All())
+	require.Equal(t, []dag.INode{alice, rex, bob}, dag.Ancestors[dag.INode](june).All())
+	require.Equal(t, []*Person(nil), dag.Descendants[*Person](carol).All())
+
+	require.Equal(t, []dag.INode{bob, alice, june}, dag.ShortestPath(bob, june))
+	require.Equal(t, []dag.INode{bob}, dag.ShortestPath(bob, bob))
+	require.Nil(t, dag.ShortestPath(june, bob))
+
+	sorted, err := dag.TopologicalSort(g)
+	require.NoError(t, err)
+	require.Equal(t, []dag.INode{bob, carol, alice, rex, june}, sorted)
+	require.False(t, dag.HasCycle(g))
+
+	g.AddEdge(june, bob)
+
+	require.Equal(t, []dag.INode{bob, alice, june}, dag.FindCycle(g))
+	require.Contains(t, dag.Descendants[dag.INode](bob).All(), bob)
+
+	_, err = dag.TopologicalSort(g)
+	require.True(t, errors.Is(err, dag.ErrCycle))
+}
+
+func TestExport(t *testing.T) {
+	g := dag.New()
+
+	bob := &Person{Name: "bob"}
+	june := &Cat{Name: "june"}
+	alice := &Person{Name: "alice"}
+
+	g.AddNode("bob", bob)
+	g.AddNode("june", june)
+	g.AddNode("alice", alice)
+	g.AddEdge(bob, alice)
+	g.AddEdge(alice, june)
+
+	opts := dag.ExportOptions{
+		Cluster: func(n dag.INode) []string {
+			if _, ok := n.(*Person); ok {
+				return []string{"people"}
+			}
+			return nil
+		},
+		Highlight: []dag.INode{june},
+	}
+
+	require.Equal(t, `digraph {
+	node [shape=box];
+	n1 [label="Cat{Name: june}", style=filled, fillcolor="#ff9999"];
+	subgraph cluster_1 {
+		label="people";
+		n0 [label="Person{Name: bob}"];
+		n2 [label="Person{Name: alice}"];
+	}
+	n0 -> n2;
+	n2 -> n1;
+}
+`, g.DOT(opts))
+
+	require.Equal(t, `flowchart TD
+    n1["Cat{Name: june}"]
+    subgraph c1 ["people"]
+        n0["Person{Name: bob}"]
+        n2["Person{Name: alice}"]
+    end
+    n0 --> n2
+    n2 --> n1
+    classDef highlight fill:#ff9999
+    class n1 highlight
+`, g.Mermaid(opts))
+}
+
+func TestJSON(t *testing.T) {
+	g := dag.New()
+
+	bob := &Person{Name: "bob"}
+	june := &Cat{Name: "june"}
+	alice := &Person{Name: "alice"}
+
+	g.AddNode("bob", bob)
+	g.AddNode("june", june)
+	g.AddNode("alice", alice)
+	g.AddEdge(bob, alice)
+	g.AddEdge(alice, june)
+
+	encoded, err := json.Marshal(g)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"nodes": [
+			{"id": "bob", "type": "github.com/chrisseto/scwl/pkg/dag_test.Person", "node": {"Name": "bob"}},
+			{"id": "june", "type": "github.com/chrisseto/scwl/pkg/dag_test.Cat", "node": {"Name": "june"}},
+			{"id": "alice", "type": "github.com/chrisseto/scwl/pkg/dag_test.Person", "node": {"Name": "alice"}}
+		],
+		"edges": [["bob", "alice"], ["alice", "june"]]
+	}`, string(encoded))
+
+	var decoded dag.Graph
+	require.NoError(t, json.Unmarshal(encoded, &decoded))
+
+	require.Equal(t, g.String(), decoded.String())
+	require.Equal(t, g.Comparable(), decoded.Comparable())
+	require.Equal(t, []string{"alice"}, names(dag.Outgoing[*Person](dag.ByID[*Person](&decoded, "bob"))))
+	require.Equal(t, []string{"alice"}, names(dag.Incoming[*Person](dag.ByID[*Cat](&decoded, "june"))))
+
+	require.Error(t, json.Unmarshal([]byte(`{"nodes": [{"id": "x", "type": "Unicorn"}]}`), dag.New()))
+
+	// Edges are part of the comparison.
+	rewired := dag.New()
+	rewired.AddNode("bob", &Person{Name: "bob"})
+	rewired.AddNode("june", &Cat{Name: "june"})
+	rewired.AddNode("alice", &Person{Name: "alice"})
+	rewired.AddEdge(rewired.ByID("bob"), rewired.ByID("june"))
+	rewired.AddEdge(rewired.ByID("alice"), rewired.ByID("june"))
+	require.NotEqual(t, g.Comparable(), rewired.Comparable())
+}
+
+func TestJSONSameName(t *testing.T) {
+	g := dag.New()
+	g.AddEdge(g.AddNode("outer", &Node{}), g.AddNode("inner", &dag.Node{}))
+
+	encoded, err := json.Marshal(g)
+	require.NoError(t, err)
+
+	var decoded dag.Graph
+	require.NoError(t, json.Unmarshal(encoded, &decoded))
+	require.IsType(t, &Node{}, decoded.ByID("outer"))
+	require.IsType(t, &dag.Node{}, decoded.ByID("inner"))
+}
+
+func names(people []*Person) []string {
+	var out []string
+	for _, p := range people {
+		out = append(out, p.Name)
+	}
+	return out
+}
+
 // func TestString(t *testing.T) {
-// 	g := dag.New(nil)
+// 	g := dag.New()
 //
 // 	bob := &Person{Name: "bob"}
 // 	june := &Cat{Name: "june"}
@@ -69,22 +283,3 @@ func TestDag(t *testing.T) {
 // 	g.AddEdge(g.ByID("1"), g.ByID("2"))
 // 	`, g.String())
 // }
-
-func clone(in dag.INode) dag.INode {
-	// Go is weird. We can't clone a struct that implements an interface
-	// without doing some reflection magic. Instead, opted for a repetitive
-	// type switch. Could probably code gen it.
-	switch n := in.(type) {
-	case *Person:
-		o := *n
-		return &o
-	case *Cat:
-		o := *n
-		return &o
-	case *Dog:
-		o := *n
-		return &o
-	default:
-		panic(errors.Newf("unhandled type %T", in))
-	}
-}
@@ -0,0 +1,217 @@
+package dag
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ExportOptions controls the rendering of [Graph.DOT] and [Graph.Mermaid].
+// The zero value is valid.
+type ExportOptions struct {
+	// Label returns the text displayed for a node. Defaults to [Describe].
+	Label func(INode) string
+
+	// Cluster returns the path of clusters, outermost first, that a node
+	// should be drawn within. Nodes that return the same path prefix share a
+	// cluster. A nil Cluster, or an empty path, draws the node at the top
+	// level.
+	Cluster func(INode) []string
+
+	// Highlight is a set of nodes that will be drawn in a distinct color, for
+	// example the nodes that differ between two graphs.
+	Highlight []INode
+}
+
+// Describe renders a node as its type name followed by its exported fields,
+// eg: `Table{Name: users}`.
+func Describe(n INode) string {
+	var b strings.Builder
+
+	v := reflect.ValueOf(n).Elem()
+	t := v.Type()
+
+	fmt.Fprintf(&b, "%s{", t.Name())
+	first := true
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous || !f.IsExported() {
+			continue
+		}
+		if !first {
+			fmt.Fprint(&b, ", ")
+		}
+		first = false
+		fmt.Fprintf(&b, "%s: %v", f.Name, v.Field(i))
+	}
+	b.WriteRune('}')
+
+	return b.String()
+}
+
+// cluster is a tree of clusters with the indexes of the nodes directly within
+// them.
+type cluster struct {
+	label    string
+	nodes    []int
+	order    []string
+	children map[string]*cluster
+}
+
+func (c *cluster) child(label string) *cluster {
+	if c.children == nil {
+		c.children = map[string]*cluster{}
+	}
+	if child, ok := c.children[label]; ok {
+		return child
+	}
+	child := &cluster{label: label}
+	c.children[label] = child
+	c.order = append(c.order, label)
+	return child
+}
+
+// walk calls enter and exit for every cluster below c, depth first, in
+// insertion order. IDs are assigned sequentially.
+func (c *cluster) walk(id *int, enter func(id int, c *cluster), exit func(c *cluster)) {
+	for _, label := range c.order {
+		child := c.children[label]
+		*id++
+		enter(*id, child)
+		child.walk(id, enter, exit)
+		exit(child)
+	}
+}
+
+func (g *Graph) exportPrep(opts ExportOptions) (labels []string, root *cluster, highlight map[INode]bool, index map[INode]int) {
+	label := opts.Label
+	if label == nil {
+		label = Describe
+	}
+
+	root = &cluster{}
+	labels = make([]string, len(g.nodes))
+	index = make(map[INode]int, len(g.nodes))
+	for i, n := range g.nodes {
+		index[n] = i
+		labels[i] = label(n)
+
+		c := root
+		if opts.Cluster != nil {
+			for _, l := range opts.Cluster(n) {
+				c = c.child(l)
+			}
+		}
+		c.nodes = append(c.nodes, i)
+	}
+
+	highlight = make(map[INode]bool, len(opts.Highlight))
+	for _, n := range opts.Highlight {
+		highlight[n] = true
+	}
+
+	return labels, root, highlight, index
+}
+
+// DOT renders the graph in the Graphviz DOT language.
+func (g *Graph) DOT(opts ExportOptions) string {
+	var b bytes.Buffer
+
+	labels, root, highlight, index := g.exportPrep(opts)
+
+	depth := 1
+	indent := func() string { return strings.Repeat("\t", depth) }
+
+	writeNodes := func(c *cluster) {
+		for _, i := range c.nodes {
+			fmt.Fprintf(&b, "%sn%d [label=%s", indent(), i, dotQuote(labels[i]))
+			if highlight[g.nodes[i]] {
+				fmt.Fprint(&b, `, style=filled, fillcolor="#ff9999"`)
+			}
+			fmt.Fprint(&b, "];\n")
+		}
+	}
+
+	fmt.Fprint(&b, "digraph {\n")
+	fmt.Fprint(&b, "\tnode [shape=box];\n")
+	writeNodes(root)
+
+	var id int
+	root.walk(&id, func(id int, c *cluster) {
+		fmt.Fprintf(&b, "%ssubgraph cluster_%d {\n", indent(), id)
+		depth++
+		fmt.Fprintf(&b, "%slabel=%s;\n", indent(), dotQuote(c.label))
+		writeNodes(c)
+	}, func(c *cluster) {
+		depth--
+		fmt.Fprintf(&b, "%s}\n", indent())
+	})
+
+	for _, from := range g.nodes {
+		for _, to := range g.outgoing[from] {
+			fmt.Fprintf(&b, "\tn%d -> n%d;\n", index[from], index[to])
+		}
+	}
+
+	fmt.Fprint(&b, "}\n")
+
+	return b.String()
+}
+
+// Mermaid renders the graph as a Mermaid flowchart.
+func (g *Graph) Mermaid(opts ExportOptions) string {
+	var b bytes.Buffer
+
+	labels, root, highlight, index := g.exportPrep(opts)
+
+	depth := 1
+	indent := func() string { return strings.Repeat("    ", depth) }
+
+	writeNodes := func(c *cluster) {
+		for _, i := range c.nodes {
+			fmt.Fprintf(&b, "%sn%d[%s]\n", indent(), i, mermaidQuote(labels[i]))
+		}
+	}
+
+	fmt.Fprint(&b, "flowchart TD\n")
+	writeNodes(root)
+
+	var id int
+	root.walk(&id, func(id int, c *cluster) {
+		fmt.Fprintf(&b, "%ssubgraph c%d [%s]\n", indent(), id, mermaidQuote(c.label))
+		depth++
+		writeNodes(c)
+	}, func(c *cluster) {
+		depth--
+		fmt.Fprintf(&b, "%send\n", indent())
+	})
+
+	for _, from := range g.nodes {
+		for _, to := range g.outgoing[from] {
+			fmt.Fprintf(&b, "    n%d --> n%d\n", index[from], index[to])
+		}
+	}
+
+	var highlighted []string
+	for i, n := range g.nodes {
+		if highlight[n] {
+			highlighted = append(highlighted, fmt.Sprintf("n%d", i))
+		}
+	}
+
+	if len(highlighted) > 0 {
+		fmt.Fprint(&b, "    classDef highlight fill:#ff9999\n")
+		fmt.Fprintf(&b, "    class %s highlight\n", strings.Join(highlighted, ","))
+	}
+
+	return b.String()
+}
+
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+func mermaidQuote(s string) string {
+	return `"` + strings.NewReplacer(`"`, "#quot;", "\n", "<br>").Replace(s) + `"`
+}
@@ -0,0 +1,92 @@
+package dag
+
+import (
+	"encoding/json"
+
+	"github.com/cockroachdb/errors"
+)
+
+// jsonGraph is the serialized form of a [Graph]. Nodes are tagged with the
+// name their type was [Register]ed under and edges refer to nodes by ID.
+type jsonGraph struct {
+	Nodes []jsonNode  `json:"nodes"`
+	Edges [][2]string `json:"edges"`
+}
+
+type jsonNode struct {
+	ID   string          `json:"id"`
+	Type string          `json:"type"`
+	Node json.RawMessage `json:"node"`
+}
+
+// MarshalJSON implements [json.Marshaler]. All node types within the graph
+// must have been [Register]ed.
+func (g *Graph) MarshalJSON() ([]byte, error) {
+	out := jsonGraph{
+		Nodes: make([]jsonNode, len(g.nodes)),
+		Edges: [][2]string{},
+	}
+
+	for i, n := range g.nodes {
+		r, err := lookupType(n)
+		if err != nil {
+			return nil, err
+		}
+
+		raw, err := json.Marshal(n)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		out.Nodes[i] = jsonNode{ID: g.idsByNode[n], Type: r.name, Node: raw}
+	}
+
+	for _, from := range g.nodes {
+		for _, to := range g.outgoing[from] {
+			out.Edges = append(out.Edges, [2]string{g.idsByNode[from], g.idsByNode[to]})
+		}
+	}
+
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON implements [json.Unmarshaler]. Nodes and edges are added to
+// g, which is expected to be empty. All node types within the serialized
+// graph must have been [Register]ed.
+func (g *Graph) UnmarshalJSON(data []byte) error {
+	var in jsonGraph
+	if err := json.Unmarshal(data, &in); err != nil {
+		return errors.WithStack(err)
+	}
+
+	if g.nodesByID == nil {
+		g.nodesByID = make(map[string]INode, len(in.Nodes))
+		g.idsByNode = make(map[INode]string, len(in.Nodes))
+		g.outgoing = make(map[INode][]INode)
+		g.incoming = make(map[INode][]INode)
+	}
+
+	for _, jn := range in.Nodes {
+		r, err := lookupName(jn.Type)
+		if err != nil {
+			return err
+		}
+
+		n := r.new()
+		if err := json.Unmarshal(jn.Node, n); err != nil {
+			return errors.Wrapf(err, "decoding node %q", jn.ID)
+		}
+
+		g.AddNode(jn.ID, n)
+	}
+
+	for _, edge := range in.Edges {
+		from, to := g.ByID(edge[0]), g.ByID(edge[1])
+		if from == nil || to == nil {
+			return errors.Newf("edge %q -> %q references an unknown node", edge[0], edge[1])
+		}
+		g.AddEdge(from, to)
+	}
+
+	return nil
+}
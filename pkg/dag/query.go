@@ -15,7 +15,7 @@ func Nodes[T INode](g *Graph, predicates ...Filter[T]) Result[T] {
 }
 
 func Incoming[T INode](n INode, predicates ...Filter[T]) Result[T] {
-	return filter[T](n.graph().incoming[n])
+	return filter(n.graph().incoming[n], predicates...)
 }
 
 func Outgoing[T INode](n INode, predicates ...Filter[T]) Result[T] {
@@ -26,25 +26,20 @@ func Outgoing[T INode](n INode, predicates ...Filter[T]) Result[T] {
 }
 
 func filter[T INode](in []INode, predicates ...Filter[T]) []T {
-	pred := func(n INode) bool {
-		t, ok := n.(T)
-		if !ok {
-			return false
-		}
-
-		for _, p := range predicates {
-			if !p(t) {
-				return false
-			}
-		}
-		return true
-	}
-
 	var out []T
 	for _, n := range in {
-		if pred(n) {
-			out = append(out, n.(T))
+		if t, ok := n.(T); ok && matches(t, predicates) {
+			out = append(out, t)
 		}
 	}
 	return out
 }
+
+func matches[T INode](t T, predicates []Filter[T]) bool {
+	for _, p := range predicates {
+		if !p(t) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,80 @@
+package dag
+
+import (
+	"reflect"
+
+	"github.com/cockroachdb/errors"
+)
+
+// registration describes a node type that has been made known to the dag
+// package via [Register].
+type registration struct {
+	name  string
+	new   func() INode
+	clone func(INode) INode
+}
+
+var (
+	registryByName = map[string]*registration{}
+	registryByType = map[reflect.Type]*registration{}
+)
+
+// Register makes the node type *T known to the dag package, allowing graphs
+// containing it to be cloned and serialized. Types are identified by their
+// package path and name, so types of the same name from different packages
+// don't collide. It's expected to be called from an init function. Usage:
+//
+//	func init() {
+//		dag.Register[Entity]()
+//	}
+func Register[T any, PT interface {
+	*T
+	INode
+}]() {
+	typ := reflect.TypeOf(PT(nil))
+	name := typ.Elem().PkgPath() + "." + typ.Elem().Name()
+
+	if _, ok := registryByName[name]; ok {
+		panic(errors.Newf("node type %q registered twice", name))
+	}
+
+	r := &registration{
+		name: name,
+		new:  func() INode { return PT(new(T)) },
+		clone: func(n INode) INode {
+			c := *n.(PT)
+			return PT(&c)
+		},
+	}
+
+	registryByName[name] = r
+	registryByType[typ] = r
+}
+
+// Clone returns a shallow copy of n that is not attached to any graph. n's type
+// must have been [Register]ed.
+func Clone(n INode) INode {
+	r, err := lookupType(n)
+	if err != nil {
+		panic(err)
+	}
+	c := r.clone(n)
+	c.setGraph(nil)
+	return c
+}
+
+func lookupType(n INode) (*registration, error) {
+	r, ok := registryByType[reflect.TypeOf(n)]
+	if !ok {
+		return nil, errors.Newf("unregistered node type %T", n)
+	}
+	return r, nil
+}
+
+func lookupName(name string) (*registration, error) {
+	r, ok := registryByName[name]
+	if !ok {
+		return nil, errors.Newf("unregistered node type %q", name)
+	}
+	return r, nil
+}
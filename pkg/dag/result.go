@@ -2,10 +2,19 @@ package dag
 
 import (
 	"math/rand"
+	"sort"
 
 	"github.com/cockroachdb/errors"
 )
 
+var (
+	// ErrNoResults is returned when a Result is unexpectedly empty.
+	ErrNoResults = errors.New("no results")
+	// ErrMultipleResults is returned when a Result unexpectedly contains more
+	// than one element.
+	ErrMultipleResults = errors.New("multiple results")
+)
+
 type Result[T INode] []T
 
 func (q Result[T]) All(predicates ...func(T) bool) []T {
@@ -36,6 +45,23 @@ func (q Result[T]) All(predicates ...func(T) bool) []T {
 	return out
 }
 
+// Filter returns the elements of q that satisfy all predicates. It may be
+// chained with other methods of Result.
+func (q Result[T]) Filter(predicates ...Filter[T]) Result[T] {
+	var out Result[T]
+	for _, n := range q {
+		if matches(n, predicates) {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// Count returns the number of elements in q.
+func (q Result[T]) Count() int {
+	return len(q)
+}
+
 // Pick a random selection of exactly n elements. Panics if there are less than
 // n total elements.
 func (q Result[T]) Pick(n int) []T {
@@ -45,38 +71,120 @@ func (q Result[T]) Pick(n int) []T {
 	if len(q) == n {
 		return q.All()
 	}
-	picked := map[int]bool{}
-	for len(picked) < n {
-		picked[rand.Intn(n)] = true
-	}
 
-	var out []T
-	for i := range q {
-		if picked[i] {
-			out = append(out, q[i])
-		}
+	// Preserve the original ordering of the picked elements.
+	picked := rand.Perm(len(q))[:n]
+	sort.Ints(picked)
+
+	out := make([]T, n)
+	for i, idx := range picked {
+		out[i] = q[idx]
 	}
 	return out
 }
 
+// PickUpTo returns a random selection of between 1 and n, inclusive,
+// elements. Fewer than n elements will be returned if q is smaller than n.
 func (q Result[T]) PickUpTo(n int) []T {
 	return q.PickBetween(1, n)
 }
 
+// PickBetween returns a random selection of between min and max, inclusive,
+// elements. Panics if there are less than min total elements.
 func (q Result[T]) PickBetween(min, max int) []T {
 	if len(q) < max {
 		max = len(q)
 	}
-	return q.Pick(min + rand.Intn(max-min))
+	if max < min {
+		panic(errors.Newf("PickBetween(%d, _) called on Result with %d elements", min, len(q)))
+	}
+	return q.Pick(min + rand.Intn(max-min+1))
 }
 
-func (q Result[T]) One() T {
-	if len(q) != 1 {
-		panic("TODO")
+// Shuffle returns a copy of q in a random order.
+func (q Result[T]) Shuffle() Result[T] {
+	out := make(Result[T], len(q))
+	copy(out, q)
+	rand.Shuffle(len(out), func(i, j int) {
+		out[i], out[j] = out[j], out[i]
+	})
+	return out
+}
+
+// First returns the first element of q. Panics if q is empty.
+func (q Result[T]) First() T {
+	if len(q) == 0 {
+		panic(errors.Wrap(ErrNoResults, "First() called on empty Result"))
 	}
 	return q[0]
 }
 
+// One returns the only element of q. Panics if q does not contain exactly
+// one element.
+func (q Result[T]) One() T {
+	t, err := q.TryOne()
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// TryOne is like [Result.One] but returns an error instead of panicking.
+func (q Result[T]) TryOne() (T, error) {
+	var zero T
+	switch len(q) {
+	case 0:
+		return zero, errors.Wrapf(ErrNoResults, "expected exactly one %T", zero)
+	case 1:
+		return q[0], nil
+	default:
+		return zero, errors.Wrapf(ErrMultipleResults, "expected exactly one %T, found %d", zero, len(q))
+	}
+}
+
+// Any returns a random element of q. Panics if q is empty.
 func (q Result[T]) Any() T {
-	return q.Pick(1)[0]
+	t, err := q.TryAny()
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// TryAny is like [Result.Any] but returns an error instead of panicking.
+func (q Result[T]) TryAny() (T, error) {
+	if len(q) == 0 {
+		var zero T
+		return zero, errors.Wrapf(ErrNoResults, "expected at least one %T", zero)
+	}
+	return q[rand.Intn(len(q))], nil
+}
+
+// Weighted returns a random element of q where the likelihood of any element
+// being chosen is proportional to weight. Elements with a weight of 0 or less
+// are never chosen. Panics if no element has a positive weight.
+func (q Result[T]) Weighted(weight func(T) int) T {
+	weights := make([]int, len(q))
+	total := 0
+	for i, n := range q {
+		if w := weight(n); w > 0 {
+			weights[i] = w
+			total += w
+		}
+	}
+
+	if total == 0 {
+		var zero T
+		panic(errors.Wrapf(ErrNoResults, "no %T with a positive weight", zero))
+	}
+
+	r := rand.Intn(total)
+	for i, w := range weights {
+		if r < w {
+			return q[i]
+		}
+		r -= w
+	}
+
+	panic("unreachable")
 }
@@ -0,0 +1,172 @@
+package dag
+
+import (
+	"github.com/cockroachdb/errors"
+)
+
+// ErrCycle is returned by operations that require a graph to be acyclic.
+var ErrCycle = errors.New("graph contains a cycle")
+
+// Descendants returns all nodes reachable from n by following outgoing edges,
+// nearest first. n itself is only included if it's part of a cycle.
+func Descendants[T INode](n INode, predicates ...Filter[T]) Result[T] {
+	return filter(reachable(n, n.graph().outgoing), predicates...)
+}
+
+// Ancestors returns all nodes from which n is reachable by following
+// incoming edges, nearest first. n itself is only included if it's part of a
+// cycle.
+func Ancestors[T INode](n INode, predicates ...Filter[T]) Result[T] {
+	return filter(reachable(n, n.graph().incoming), predicates...)
+}
+
+// reachable performs a breadth first search from start across edges.
+func reachable(start INode, edges map[INode][]INode) []INode {
+	var out []INode
+	seen := map[INode]bool{}
+	queue := []INode{start}
+
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+
+		for _, next := range edges[n] {
+			if seen[next] {
+				continue
+			}
+			seen[next] = true
+			out = append(out, next)
+			queue = append(queue, next)
+		}
+	}
+
+	return out
+}
+
+// ShortestPath returns the shortest path, inclusive of both ends, from from
+// to to by following outgoing edges. nil is returned if to is not reachable.
+func ShortestPath(from, to INode) []INode {
+	if from == to {
+		return []INode{from}
+	}
+
+	edges := from.graph().outgoing
+	parents := map[INode]INode{from: nil}
+	queue := []INode{from}
+
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+
+		for _, next := range edges[n] {
+			if _, ok := parents[next]; ok {
+				continue
+			}
+			parents[next] = n
+
+			if next == to {
+				var path []INode
+				for cur := to; cur != nil; cur = parents[cur] {
+					path = append([]INode{cur}, path...)
+				}
+				return path
+			}
+
+			queue = append(queue, next)
+		}
+	}
+
+	return nil
+}
+
+// TopologicalSort returns the nodes of g ordered such that every node appears
+// before all of the nodes it has outgoing edges to. Ties are broken by the
+// order in which nodes were added to g. An error wrapping [ErrCycle] is
+// returned if g contains a cycle.
+func TopologicalSort(g *Graph) ([]INode, error) {
+	inDegree := make(map[INode]int, len(g.nodes))
+	for _, n := range g.nodes {
+		inDegree[n] = len(g.incoming[n])
+	}
+
+	var ready []INode
+	for _, n := range g.nodes {
+		if inDegree[n] == 0 {
+			ready = append(ready, n)
+		}
+	}
+
+	out := make([]INode, 0, len(g.nodes))
+	for len(ready) > 0 {
+		n := ready[0]
+		ready = ready[1:]
+		out = append(out, n)
+
+		for _, next := range g.outgoing[n] {
+			inDegree[next]--
+			if inDegree[next] == 0 {
+				ready = append(ready, next)
+			}
+		}
+	}
+
+	if len(out) != len(g.nodes) {
+		return nil, errors.Wrapf(ErrCycle, "cycle through %s", Describe(FindCycle(g)[0]))
+	}
+
+	return out, nil
+}
+
+// FindCycle returns the nodes that form a cycle within g, if any, such that
+// each node has an outgoing edge to the next and the last node has an
+// outgoing edge to the first. nil is returned if g is acyclic.
+func FindCycle(g *Graph) []INode {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	state := make(map[INode]int, len(g.nodes))
+	var stack []INode
+
+	var visit func(n INode) []INode
+	visit = func(n INode) []INode {
+		state[n] = visiting
+		stack = append(stack, n)
+
+		for _, next := range g.outgoing[n] {
+			switch state[next] {
+			case visiting:
+				for i := len(stack) - 1; i >= 0; i-- {
+					if stack[i] == next {
+						return append([]INode(nil), stack[i:]...)
+					}
+				}
+			case unvisited:
+				if cycle := visit(next); cycle != nil {
+					return cycle
+				}
+			}
+		}
+
+		stack = stack[:len(stack)-1]
+		state[n] = visited
+		return nil
+	}
+
+	for _, n := range g.nodes {
+		if state[n] == unvisited {
+			if cycle := visit(n); cycle != nil {
+				return cycle
+			}
+		}
+	}
+
+	return nil
+}
+
+// HasCycle returns true if g contains a cycle.
+func HasCycle(g *Graph) bool {
+	return FindCycle(g) != nil
+}
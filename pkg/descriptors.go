@@ -0,0 +1,304 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// DescriptorValidator is implemented by Systems that can check their catalog
+// for corruption that may not be visible in their State.
+type DescriptorValidator interface {
+	ValidateDescriptors(context.Context) error
+}
+
+// ForeignKeyReference is a foreign key as recorded on both its origin and
+// referenced table descriptors.
+type ForeignKeyReference struct {
+	Name                string  `json:"name"`
+	OriginTableID       int64   `json:"originTableId"`
+	OriginColumnIDs     []int64 `json:"originColumnIds"`
+	ReferencedTableID   int64   `json:"referencedTableId"`
+	ReferencedColumnIDs []int64 `json:"referencedColumnIds"`
+}
+
+func (r ForeignKeyReference) String() string {
+	return fmt.Sprintf("%s (%d%v -> %d%v)", r.Name, r.OriginTableID, r.OriginColumnIDs, r.ReferencedTableID, r.ReferencedColumnIDs)
+}
+
+// Descriptor is the subset of a descriptor, as decoded by
+// crdb_internal.pb_to_json, that CheckDescriptors validates.
+type Descriptor struct {
+	// Kind is the type of descriptor, eg: table, schema or database.
+	Kind           string
+	ID             int64
+	Name           string
+	ParentID       int64
+	ParentSchemaID int64
+	// State is PUBLIC, ADD, DROP or OFFLINE.
+	State        string
+	OutboundFKs  []ForeignKeyReference
+	InboundFKs   []ForeignKeyReference
+	DependsOn    []int64
+	DependedOnBy []int64
+}
+
+func (d Descriptor) String() string {
+	return fmt.Sprintf("%s %q (%d)", d.Kind, d.Name, d.ID)
+}
+
+// ParseDescriptor decodes the output of crdb_internal.pb_to_json for a
+// cockroach.sql.sqlbase.Descriptor. Fields with default values are omitted by
+// pb_to_json, so a missing state is PUBLIC.
+func ParseDescriptor(data []byte) (Descriptor, error) {
+	var wrapper map[string]struct {
+		ID                      int64                 `json:"id"`
+		Name                    string                `json:"name"`
+		ParentID                int64                 `json:"parentId"`
+		ParentSchemaID          int64                 `json:"parentSchemaId"`
+		UnexposedParentSchemaID int64                 `json:"unexposedParentSchemaId"`
+		State                   string                `json:"state"`
+		OutboundFKs             []ForeignKeyReference `json:"outboundFks"`
+		InboundFKs              []ForeignKeyReference `json:"inboundFks"`
+		DependsOn               []int64               `json:"dependsOn"`
+		DependedOnBy            []struct {
+			ID int64 `json:"id"`
+		} `json:"dependedOnBy"`
+	}
+
+	if err := json.Unmarshal(data, &wrapper); err != nil {
+		return Descriptor{}, errors.WithStack(err)
+	}
+
+	if len(wrapper) != 1 {
+		return Descriptor{}, errors.Newf("expected a single descriptor, found %d", len(wrapper))
+	}
+
+	for kind, raw := range wrapper {
+		desc := Descriptor{
+			Kind:           kind,
+			ID:             raw.ID,
+			Name:           raw.Name,
+			ParentID:       raw.ParentID,
+			ParentSchemaID: raw.ParentSchemaID,
+			State:          raw.State,
+			OutboundFKs:    raw.OutboundFKs,
+			InboundFKs:     raw.InboundFKs,
+			DependsOn:      raw.DependsOn,
+		}
+		// Tables record their schema under a different name than types
+		// and functions.
+		if kind == "table" {
+			desc.ParentSchemaID = raw.UnexposedParentSchemaID
+		}
+		if desc.State == "" {
+			desc.State = "PUBLIC"
+		}
+		for _, ref := range raw.DependedOnBy {
+			desc.DependedOnBy = append(desc.DependedOnBy, ref.ID)
+		}
+		return desc, nil
+	}
+	panic("unreachable")
+}
+
+// NamespaceEntry is a row of system.namespace.
+type NamespaceEntry struct {
+	ParentID       int64  `db:"parentID"`
+	ParentSchemaID int64  `db:"parentSchemaID"`
+	Name           string `db:"name"`
+	ID             int64  `db:"id"`
+}
+
+// CheckDescriptors returns an error describing every inconsistency between
+// descs and namespace. Foreign keys must be recorded on both tables that they
+// relate, dependencies must be on descriptors that exist and record the
+// dependency in return, and every descriptor that isn't being dropped must
+// have a matching namespace entry and vice versa. Dropped descriptors are
+// only checked for references to them, as they linger until garbage
+// collected.
+func CheckDescriptors(descs []Descriptor, namespace []NamespaceEntry) error {
+	byID := make(map[int64]*Descriptor, len(descs))
+	for i := range descs {
+		byID[descs[i].ID] = &descs[i]
+	}
+
+	live := func(id int64) (*Descriptor, bool) {
+		desc, ok := byID[id]
+		return desc, ok && desc.State != "DROP"
+	}
+
+	hasFK := func(refs []ForeignKeyReference, ref ForeignKeyReference) bool {
+		for _, r := range refs {
+			if r.String() == ref.String() {
+				return true
+			}
+		}
+		return false
+	}
+
+	contains := func(ids []int64, id int64) bool {
+		for _, i := range ids {
+			if i == id {
+				return true
+			}
+		}
+		return false
+	}
+
+	var problems []string
+	report := func(desc *Descriptor, format string, args ...interface{}) {
+		problems = append(problems, desc.String()+": "+fmt.Sprintf(format, args...))
+	}
+
+	for i := range descs {
+		desc := &descs[i]
+		if desc.State == "DROP" {
+			continue
+		}
+
+		for _, fk := range desc.OutboundFKs {
+			if referenced, ok := live(fk.ReferencedTableID); !ok {
+				report(desc, "outbound foreign key %s references a missing or dropped table", fk)
+			} else if !hasFK(referenced.InboundFKs, fk) {
+				report(desc, "outbound foreign key %s has no inbound reference on %s", fk, referenced)
+			}
+		}
+
+		for _, fk := range desc.InboundFKs {
+			if origin, ok := live(fk.OriginTableID); !ok {
+				report(desc, "inbound foreign key %s references a missing or dropped table", fk)
+			} else if !hasFK(origin.OutboundFKs, fk) {
+				report(desc, "inbound foreign key %s has no outbound reference on %s", fk, origin)
+			}
+		}
+
+		for _, id := range desc.DependsOn {
+			if dependency, ok := live(id); !ok {
+				report(desc, "depends on missing or dropped descriptor %d", id)
+			} else if !contains(dependency.DependedOnBy, desc.ID) {
+				report(desc, "depends on %s which doesn't record the dependency", dependency)
+			}
+		}
+
+		// Tables that own sequences or are referenced by column defaults
+		// record it elsewhere, so only existence is checked.
+		for _, id := range desc.DependedOnBy {
+			if _, ok := live(id); !ok {
+				report(desc, "depended on by missing or dropped descriptor %d", id)
+			}
+		}
+	}
+
+	entries := make(map[int64][]NamespaceEntry, len(namespace))
+	for _, entry := range namespace {
+		entries[entry.ID] = append(entries[entry.ID], entry)
+	}
+
+	for i := range descs {
+		desc := &descs[i]
+		// Functions are named within their schema's descriptor rather than
+		// system.namespace.
+		if desc.State == "DROP" || desc.Kind == "function" {
+			continue
+		}
+
+		// Databases are parented by nothing and schemas by only their
+		// database.
+		want := NamespaceEntry{ParentID: desc.ParentID, ParentSchemaID: desc.ParentSchemaID, Name: desc.Name, ID: desc.ID}
+		if len(entries[desc.ID]) != 1 || entries[desc.ID][0] != want {
+			report(desc, "expected namespace entry %+v, found %+v", want, entries[desc.ID])
+		}
+	}
+
+	for _, entry := range namespace {
+		if _, ok := live(entry.ID); !ok {
+			problems = append(problems, fmt.Sprintf("namespace entry %+v references a missing or dropped descriptor", entry))
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return errors.Newf("invalid descriptors:\n\t%s", strings.Join(problems, "\n\t"))
+	}
+	return nil
+}
+
+// minUserDescriptorID is the lowest ID of a descriptor that isn't part of the
+// system database.
+const minUserDescriptorID = 100
+
+// ValidateDescriptors implements [DescriptorValidator].
+//
+// crdb_internal.invalid_objects runs CockroachDB's full descriptor validation,
+// the same as `cockroach debug doctor`, over every descriptor in the catalog.
+// That covers each descriptor's own invariants as well as its cross references:
+// back-references, parent databases and schemas, and namespace entries. An
+// empty invalid_objects is therefore all CockroachDB can tell us about the
+// catalog's health without a separate debug zip. CheckDescriptors then re-checks
+// the user descriptors independently, so that a bug in that validation doesn't
+// hide corruption from us.
+func (o *sut) ValidateDescriptors(ctx context.Context) error {
+	const invalidObjectsQuery = `SELECT
+		id,
+		coalesce(database_name, '') AS database_name,
+		coalesce(schema_name, '') AS schema_name,
+		coalesce(obj_name, '') AS obj_name,
+		error
+	FROM crdb_internal.invalid_objects
+	ORDER BY id
+	`
+
+	const descriptorsQuery = `SELECT
+		crdb_internal.pb_to_json('cockroach.sql.sqlbase.Descriptor', descriptor)::STRING
+	FROM system.descriptor
+	WHERE id >= $1
+	ORDER BY id
+	`
+
+	const namespaceQuery = `SELECT "parentID", "parentSchemaID", name, id FROM system.namespace WHERE id >= $1`
+
+	var invalid []struct {
+		ID       int64  `db:"id"`
+		Database string `db:"database_name"`
+		Schema   string `db:"schema_name"`
+		Name     string `db:"obj_name"`
+		Error    string `db:"error"`
+	}
+	if err := o.conn.SelectContext(ctx, &invalid, invalidObjectsQuery); err != nil {
+		return errors.WithStack(err)
+	}
+
+	if len(invalid) > 0 {
+		problems := make([]string, len(invalid))
+		for i, obj := range invalid {
+			problems[i] = fmt.Sprintf("%s.%s.%s (%d): %s", obj.Database, obj.Schema, obj.Name, obj.ID, obj.Error)
+		}
+		return errors.Newf("crdb_internal.invalid_objects is not empty:\n\t%s", strings.Join(problems, "\n\t"))
+	}
+
+	var raw []string
+	if err := o.conn.SelectContext(ctx, &raw, descriptorsQuery, minUserDescriptorID); err != nil {
+		return errors.WithStack(err)
+	}
+
+	descs := make([]Descriptor, len(raw))
+	for i, data := range raw {
+		desc, err := ParseDescriptor([]byte(data))
+		if err != nil {
+			return errors.Wrapf(err, "decoding %s", data)
+		}
+		descs[i] = desc
+	}
+
+	var namespace []NamespaceEntry
+	if err := o.conn.SelectContext(ctx, &namespace, namespaceQuery, minUserDescriptorID); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return CheckDescriptors(descs, namespace)
+}
@@ -0,0 +1,64 @@
+package pkg_test
+
+import (
+	"testing"
+
+	"github.com/chrisseto/scwl/pkg"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckDescriptors(t *testing.T) {
+	parse := func(data string) pkg.Descriptor {
+		desc, err := pkg.ParseDescriptor([]byte(data))
+		require.NoError(t, err)
+		return desc
+	}
+
+	descs := []pkg.Descriptor{
+		parse(`{"database": {"id": 100, "name": "defaultdb"}}`),
+		parse(`{"schema": {"id": 101, "name": "public", "parentId": 100}}`),
+		parse(`{"table": {"id": 104, "name": "users", "parentId": 100, "unexposedParentSchemaId": 101,
+			"inboundFks": [{"name": "fk", "originTableId": 105, "originColumnIds": [2], "referencedTableId": 104, "referencedColumnIds": [1]}],
+			"dependedOnBy": [{"id": 106, "columnIds": [1]}]}}`),
+		parse(`{"table": {"id": 105, "name": "posts", "parentId": 100, "unexposedParentSchemaId": 101,
+			"outboundFks": [{"name": "fk", "originTableId": 105, "originColumnIds": [2], "referencedTableId": 104, "referencedColumnIds": [1]}]}}`),
+		parse(`{"table": {"id": 106, "name": "names", "parentId": 100, "unexposedParentSchemaId": 101, "dependsOn": [104]}}`),
+		parse(`{"table": {"id": 107, "name": "gone", "parentId": 100, "unexposedParentSchemaId": 101, "state": "DROP"}}`),
+	}
+
+	require.Equal(t, "PUBLIC", descs[2].State)
+	require.Equal(t, int64(101), descs[2].ParentSchemaID)
+	require.Equal(t, []int64{106}, descs[2].DependedOnBy)
+
+	namespace := []pkg.NamespaceEntry{
+		{ParentID: 0, ParentSchemaID: 0, Name: "defaultdb", ID: 100},
+		{ParentID: 100, ParentSchemaID: 0, Name: "public", ID: 101},
+		{ParentID: 100, ParentSchemaID: 101, Name: "users", ID: 104},
+		{ParentID: 100, ParentSchemaID: 101, Name: "posts", ID: 105},
+		{ParentID: 100, ParentSchemaID: 101, Name: "names", ID: 106},
+	}
+
+	require.NoError(t, pkg.CheckDescriptors(descs, namespace))
+
+	// Drop the inbound reference from users and its namespace entry.
+	users := descs[2]
+	users.InboundFKs = nil
+	corrupt := append([]pkg.Descriptor{users}, descs[3:]...)
+	corrupt = append(corrupt, descs[:2]...)
+
+	err := pkg.CheckDescriptors(corrupt, append(namespace[:2:2], namespace[3:]...))
+	require.EqualError(t, err, `invalid descriptors:
+	table "posts" (105): outbound foreign key fk (105[2] -> 104[1]) has no inbound reference on table "users" (104)
+	table "users" (104): expected namespace entry {ParentID:100 ParentSchemaID:101 Name:users ID:104}, found []`)
+
+	// Dependencies must be recorded in both directions.
+	names := descs[4]
+	names.DependsOn = []int64{105, 108}
+	err = pkg.CheckDescriptors(append([]pkg.Descriptor{names}, descs[:4]...), namespace)
+	require.EqualError(t, err, `invalid descriptors:
+	table "names" (106): depends on missing or dropped descriptor 108
+	table "names" (106): depends on table "posts" (105) which doesn't record the dependency`)
+
+	_, err = pkg.ParseDescriptor([]byte(`{}`))
+	require.EqualError(t, err, "expected a single descriptor, found 0")
+}
@@ -0,0 +1,114 @@
+package pkg
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/chrisseto/scwl/pkg/dag"
+)
+
+// ExportOptions returns [dag.ExportOptions] suitable for rendering a state
+// graph with nodes clustered by database and schema.
+func ExportOptions(highlight ...dag.INode) dag.ExportOptions {
+	return dag.ExportOptions{
+		Label:     Label,
+		Cluster:   Cluster,
+		Highlight: highlight,
+	}
+}
+
+// Label returns a short, human readable description of a node.
+func Label(el dag.INode) string {
+	switch n := el.(type) {
+	case *Database:
+		return fmt.Sprintf("database %s", n.Name)
+	case *Schema:
+		return fmt.Sprintf("schema %s", n.Name)
+	case *Table:
+		return fmt.Sprintf("table %s", n.Name)
+	case *Column:
+		return fmt.Sprintf("column %s", n.Name)
+	case *Index:
+		if n.Unique {
+			return fmt.Sprintf("unique index %s", n.Name)
+		}
+		return fmt.Sprintf("index %s", n.Name)
+	case *ForeignKeyConstraint:
+		return fmt.Sprintf("foreign key %s", n.Name)
+	default:
+		return dag.Describe(el)
+	}
+}
+
+// Cluster returns the names of the database and schema that a node resides
+// within.
+func Cluster(el dag.INode) []string {
+	switch n := el.(type) {
+	case *Database:
+		return []string{n.Name}
+	case *Schema:
+		return []string{n.Database().Name, n.Name}
+	case *Table:
+		return Cluster(n.Schema())
+	case *Column:
+		return Cluster(n.Table())
+	case *Index:
+		return Cluster(n.Table())
+	case *ForeignKeyConstraint:
+		return Cluster(n.From())
+	default:
+		return nil
+	}
+}
+
+// Mismatched returns the nodes of a and b that have no equivalent in the
+// other graph. Nodes are equivalent if they have the same fully qualified
+// name, label and outgoing edges.
+func Mismatched(a, b *dag.Graph) (onlyA, onlyB []dag.INode) {
+	counts := map[string]int{}
+	for _, n := range dag.Nodes[dag.INode](b) {
+		counts[signature(n)]++
+	}
+
+	for _, n := range dag.Nodes[dag.INode](a) {
+		sig := signature(n)
+		if counts[sig] > 0 {
+			counts[sig]--
+			continue
+		}
+		onlyA = append(onlyA, n)
+	}
+
+	for _, n := range dag.Nodes[dag.INode](b) {
+		if counts[signature(n)] > 0 {
+			counts[signature(n)]--
+			onlyB = append(onlyB, n)
+		}
+	}
+
+	return onlyA, onlyB
+}
+
+// describeMismatched describes the nodes returned by [Mismatched] as missing
+// from, or extra in, the second graph.
+func describeMismatched(onlyA, onlyB []dag.INode) []string {
+	var out []string
+	for _, n := range onlyA {
+		out = append(out, fmt.Sprintf("missing %s %s", Label(n), FullyQualifiedName(n)))
+	}
+	for _, n := range onlyB {
+		out = append(out, fmt.Sprintf("extra %s %s", Label(n), FullyQualifiedName(n)))
+	}
+	return out
+}
+
+func signature(n dag.INode) string {
+	var outgoing []string
+	for _, out := range dag.Outgoing[dag.INode](n) {
+		outgoing = append(outgoing, FullyQualifiedName(out))
+	}
+	sort.Strings(outgoing)
+
+	return fmt.Sprintf("%s|%s|%s", FullyQualifiedName(n), Label(n), strings.Join(outgoing, ","))
+}
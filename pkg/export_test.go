@@ -0,0 +1,84 @@
+package pkg_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/chrisseto/scwl/pkg"
+	"github.com/chrisseto/scwl/pkg/dag"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMismatched(t *testing.T) {
+	// a has two tables named users, which share a signature.
+	duplicated := publicState("users")
+	duplicated.AddEdge(duplicated.ByID("schema"), duplicated.AddNode("users2", &pkg.Table{Name: "users"}))
+
+	for _, tc := range []struct {
+		name         string
+		a, b         *dag.Graph
+		onlyA, onlyB []string
+	}{
+		{
+			name: "equal",
+			a:    publicState("users", "users.id", "posts"),
+			b:    publicState("posts", "users", "users.id"),
+		},
+		{
+			name:  "only in b",
+			a:     publicState("users"),
+			b:     publicState("users", "users.id"),
+			onlyA: []string{"table users defaultdb.public.users"},
+			onlyB: []string{"table users defaultdb.public.users", "column id defaultdb.public.users.cols.id"},
+		},
+		{
+			name:  "renamed",
+			a:     publicState("users", "users.id", "posts"),
+			b:     publicState("users", "users.uid", "posts"),
+			onlyA: []string{"table users defaultdb.public.users", "column id defaultdb.public.users.cols.id"},
+			onlyB: []string{"table users defaultdb.public.users", "column uid defaultdb.public.users.cols.uid"},
+		},
+		{
+			// Only one of the duplicates is unmatched.
+			name:  "duplicate signatures",
+			a:     duplicated,
+			b:     publicState("users"),
+			onlyA: []string{"schema public defaultdb.public", "table users defaultdb.public.users"},
+			onlyB: []string{"schema public defaultdb.public"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			describe := func(nodes []dag.INode) []string {
+				var out []string
+				for _, n := range nodes {
+					out = append(out, pkg.Label(n)+" "+pkg.FullyQualifiedName(n))
+				}
+				return out
+			}
+
+			onlyA, onlyB := pkg.Mismatched(tc.a, tc.b)
+			require.ElementsMatch(t, tc.onlyA, describe(onlyA))
+			require.ElementsMatch(t, tc.onlyB, describe(onlyB))
+		})
+	}
+}
+
+// publicState returns a state in which defaultdb's public schema contains
+// elements, each of which is either a table's name or a column's, qualified
+// by its table's name. eg: "users", "users.id".
+func publicState(elements ...string) *dag.Graph {
+	g := dag.New()
+	db := g.AddNode("db", &pkg.Database{Name: "defaultdb"})
+	schema := g.AddNode("schema", &pkg.Schema{Name: "public"})
+	g.AddEdge(db, schema)
+
+	for _, element := range elements {
+		table, column, ok := strings.Cut(element, ".")
+		if !ok {
+			g.AddEdge(schema, g.AddNode(table, &pkg.Table{Name: table}))
+			continue
+		}
+		g.AddEdge(g.ByID(table), g.AddNode(element, &pkg.Column{Name: column}))
+	}
+	return g
+}
@@ -1,8 +1,6 @@
 package pkg
 
 import (
-	"log"
-	"math/rand"
 	"reflect"
 
 	"github.com/chrisseto/scwl/pkg/dag"
@@ -22,56 +20,200 @@ func init() {
 	}
 }
 
-var Weights = map[reflect.Type]int{
-	reflect.TypeOf(CreateIndex{}):  3,
-	reflect.TypeOf(AddColumn{}):    3,
-	reflect.TypeOf(CreateTable{}):  2,
-	reflect.TypeOf(DropDatabase{}): 0,
-	reflect.TypeOf(DropColumn{}):   0,
-	reflect.TypeOf(DropTable{}):    0,
-	reflect.TypeOf(DropSchema{}):   0,
+// ErrNoFeasibleCommands is returned by [GenerateCommand] when no Command with a
+// positive weight can be generated from the provided state.
+var ErrNoFeasibleCommands = errors.New("no feasible commands")
+
+// Generator produces random Commands of a single type.
+type Generator struct {
+	// Feasible returns true if Generate is able to produce a Command from the
+	// provided state without exceeding the profile's limits.
+	Feasible func(*Profile, *dag.Graph) bool
+	// Generate returns a random Command. It's only called if Feasible
+	// returned true for the same profile and state.
+	Generate func(*Profile, *dag.Graph) Command
+}
+
+// exists returns a Feasible function that is satisfied by any state
+// containing a T matching all predicates.
+func exists[T dag.INode](predicates ...dag.Filter[T]) func(*Profile, *dag.Graph) bool {
+	return func(_ *Profile, g *dag.Graph) bool {
+		return len(dag.Nodes[T](g, predicates...)) > 0
+	}
+}
+
+// under returns true if count is less than limit. A limit of 0 is unlimited.
+func under(count, limit int) bool {
+	return limit == 0 || count < limit
+}
+
+// hasRoomForColumns returns a filter that matches tables with fewer columns
+// than the profile's limit.
+func hasRoomForColumns(p *Profile) dag.Filter[*Table] {
+	return func(t *Table) bool { return under(len(t.Columns()), p.Limits.Columns) }
+}
+
+// indexable returns a filter that matches tables that have enough columns to
+// be indexed and fewer indexes than the profile's limit.
+func indexable(p *Profile) dag.Filter[*Table] {
+	return func(t *Table) bool {
+		return len(t.Columns()) > 1 && under(len(t.Indexes()), p.Limits.Indexes)
+	}
+}
+
+// randomName returns a random identifier that is not present in taken,
+// respecting the profile's AdversarialNames feature.
+func (p *Profile) randomName(taken ...string) string {
+	return RandomName(p.Features.AdversarialNames, taken...)
+}
+
+// databaseNames returns the names that a new database may not use.
+func databaseNames(g *dag.Graph) []string {
+	taken := []string{"system"}
+	for _, db := range dag.Nodes[*Database](g) {
+		taken = append(taken, db.Name)
+	}
+	return taken
+}
+
+// schemaNames returns the names that a new schema within db may not use.
+func schemaNames(db *Database) []string {
+	taken := []string{"public", "crdb_internal", "information_schema", "pg_catalog", "pg_extension"}
+	for _, schema := range db.Schemas() {
+		taken = append(taken, schema.Name)
+	}
+	return taken
+}
+
+// tableNames returns the names that a new table within schema may not use.
+func tableNames(schema *Schema) []string {
+	var taken []string
+	for _, table := range schema.Tables() {
+		taken = append(taken, table.Name)
+	}
+	return taken
+}
+
+// columnNames returns the names that a new column within table may not use.
+func columnNames(table *Table) []string {
+	taken := []string{"rowid"}
+	for _, column := range table.Columns() {
+		taken = append(taken, column.Name)
+	}
+	return taken
+}
+
+// constraintNames returns the names that a new index or constraint on table
+// may not use.
+func constraintNames(table *Table) []string {
+	taken := []string{table.Name + "_pkey"}
+	for _, index := range table.Indexes() {
+		taken = append(taken, index.Name)
+	}
+	for _, column := range table.Columns() {
+		for _, fk := range references(column) {
+			taken = append(taken, fk.Name)
+		}
+	}
+	return taken
+}
+
+// fkTargets returns all columns that may be referenced by a foreign key
+// constraint. That is, any column with a single column unique index that
+// has at least one column from another table within the same database to
+// reference it.
+func fkTargets(g *dag.Graph) dag.Result[*Column] {
+	var out dag.Result[*Column]
+	for _, idx := range dag.Nodes[*Index](g, func(i *Index) bool {
+		return i.Unique && len(i.Columns()) == 1
+	}) {
+		if to := idx.Columns()[0]; len(fkSources(g, to)) > 0 {
+			out = append(out, to)
+		}
+	}
+	return out
+}
+
+// fkSources returns all columns that could reference to in a foreign key
+// constraint.
+func fkSources(g *dag.Graph, to *Column) dag.Result[*Column] {
+	// Could be literally any other column, so long as it's not from the same
+	// table.
+	return dag.Nodes[*Column](g, func(c *Column) bool {
+		return c.Table().Schema().Database() == to.Table().Schema().Database() && c.Table() != to.Table()
+	})
 }
 
 // TODO: There's probably no reason to use reflect.TypeOf here. Command is
 // fine.
-var Generators = map[reflect.Type]func(*dag.Graph) Command{
+var Generators = map[reflect.Type]Generator{
 	// DROP ... https://github.com/cockroachdb/cockroach/blob/master/pkg/sql/parser/sql.y#L5447-L5455
-	reflect.TypeOf(DropDatabase{}): func(g *dag.Graph) Command { return DropDatabase{dag.Nodes[*Database](g).Any()} },
-	reflect.TypeOf(DropIndex{}):    func(g *dag.Graph) Command { return DropIndex{dag.Nodes[*Index](g).Any()} },
-	reflect.TypeOf(DropSchema{}):   func(g *dag.Graph) Command { return DropSchema{dag.Nodes[*Schema](g).Any()} },
-	reflect.TypeOf(DropTable{}):    func(g *dag.Graph) Command { return DropTable{dag.Nodes[*Table](g).Any()} },
+	reflect.TypeOf(DropDatabase{}): {
+		Feasible: exists[*Database](),
+		Generate: func(p *Profile, g *dag.Graph) Command { return DropDatabase{dag.Nodes[*Database](g).Any()} },
+	},
+	reflect.TypeOf(DropIndex{}): {
+		Feasible: exists[*Index](),
+		Generate: func(p *Profile, g *dag.Graph) Command { return DropIndex{dag.Nodes[*Index](g).Any()} },
+	},
+	reflect.TypeOf(DropSchema{}): {
+		Feasible: exists(NotPublic),
+		Generate: func(p *Profile, g *dag.Graph) Command { return DropSchema{dag.Any[*Schema](g, NotPublic)} },
+	},
+	reflect.TypeOf(DropTable{}): {
+		Feasible: exists[*Table](),
+		Generate: func(p *Profile, g *dag.Graph) Command { return DropTable{dag.Nodes[*Table](g).Any()} },
+	},
 
 	// CREATE ... https://github.com/cockroachdb/cockroach/blob/master/pkg/sql/parser/sql.y#L5058-L5070
-	reflect.TypeOf(CreateDatabase{}): func(g *dag.Graph) Command {
-		// Limit total number of databases to 5.
-		if len(dag.Nodes[*Database](g)) > 5 {
-			return nil
-		}
-		return CreateDatabase{Name: RandomString()}
+	reflect.TypeOf(CreateDatabase{}): {
+		Feasible: func(p *Profile, g *dag.Graph) bool {
+			return under(len(dag.Nodes[*Database](g)), p.Limits.Databases)
+		},
+		Generate: func(p *Profile, g *dag.Graph) Command {
+			return CreateDatabase{Name: p.randomName(databaseNames(g)...)}
+		},
 	},
-	reflect.TypeOf(CreateSchema{}): func(g *dag.Graph) Command {
-		// Limit total number of schemas to 5.
-		if len(dag.Nodes[*Schema](g)) > 2 {
-			return nil
-		}
-		return CreateSchema{Database: dag.Nodes[*Database](g).Any(), Name: RandomString()}
+	reflect.TypeOf(CreateSchema{}): {
+		Feasible: func(p *Profile, g *dag.Graph) bool {
+			return under(len(dag.Nodes[*Schema](g)), p.Limits.Schemas) && exists[*Database]()(p, g)
+		},
+		Generate: func(p *Profile, g *dag.Graph) Command {
+			db := dag.Nodes[*Database](g).Any()
+			return CreateSchema{Database: db, Name: p.randomName(schemaNames(db)...)}
+		},
 	},
-	reflect.TypeOf(CreateTable{}): func(g *dag.Graph) Command {
-		return CreateTable{
-			Schema: dag.Nodes[*Schema](g).Any(),
-			Name:   RandomString(),
-		}
+	reflect.TypeOf(CreateTable{}): {
+		Feasible: func(p *Profile, g *dag.Graph) bool {
+			return under(len(dag.Nodes[*Table](g)), p.Limits.Tables) && exists[*Schema]()(p, g)
+		},
+		Generate: func(p *Profile, g *dag.Graph) Command {
+			schema := dag.Nodes[*Schema](g).Any()
+			return CreateTable{
+				Schema: schema,
+				Name:   p.randomName(tableNames(schema)...),
+			}
+		},
 	},
-	reflect.TypeOf(CreateIndex{}): func(g *dag.Graph) Command {
-		table := dag.Nodes[*Table](g, func(t *Table) bool {
-			return len(t.Columns()) > 1
-		}).Any()
-		return CreateIndex{
-			Table:   table,
-			Name:    RandomString(),
-			Columns: table.Columns().PickUpTo(3),
-			Unique:  FlipCoin(),
-		}
+	reflect.TypeOf(CreateIndex{}): {
+		Feasible: func(p *Profile, g *dag.Graph) bool {
+			return exists(indexable(p))(p, g)
+		},
+		Generate: func(p *Profile, g *dag.Graph) Command {
+			table := dag.Any(g, indexable(p))
+
+			maxColumns := 3
+			if !p.Features.MultiColumnIndexes {
+				maxColumns = 1
+			}
+
+			return CreateIndex{
+				Table:   table,
+				Name:    p.randomName(constraintNames(table)...),
+				Columns: table.Columns().PickUpTo(maxColumns),
+				Unique:  p.Features.UniqueIndexes && FlipCoin(),
+			}
+		},
 	},
 	// CreateTableAs
 	// CreateType
@@ -81,102 +223,105 @@ var Generators = map[reflect.Type]func(*dag.Graph) Command{
 	// CreateProc
 
 	// ALTER ... https://github.com/cockroachdb/cockroach/blob/master/pkg/sql/parser/sql.y#L1816-L1830
-	reflect.TypeOf(RenameTable{}): func(g *dag.Graph) Command {
-		return RenameTable{
-			Table: dag.Any[*Table](g),
-			Name:  RandomString(),
-		}
+	reflect.TypeOf(RenameTable{}): {
+		Feasible: exists[*Table](),
+		Generate: func(p *Profile, g *dag.Graph) Command {
+			table := dag.Any[*Table](g)
+			return RenameTable{
+				Table: table,
+				Name:  p.randomName(tableNames(table.Schema())...),
+			}
+		},
 	},
-	reflect.TypeOf(RenameSchema{}): func(g *dag.Graph) Command {
-		return RenameSchema{
-			Schema: dag.Any[*Schema](g, NotPublic),
-			Name:   RandomString(),
-		}
+	reflect.TypeOf(RenameSchema{}): {
+		Feasible: exists(NotPublic),
+		Generate: func(p *Profile, g *dag.Graph) Command {
+			schema := dag.Any[*Schema](g, NotPublic)
+			return RenameSchema{
+				Schema: schema,
+				Name:   p.randomName(schemaNames(schema.Database())...),
+			}
+		},
 	},
-	reflect.TypeOf(RenameDatabase{}): func(g *dag.Graph) Command {
-		return RenameDatabase{
-			Database: dag.Any[*Database](g),
-			Name:     RandomString(),
-		}
+	reflect.TypeOf(RenameDatabase{}): {
+		Feasible: exists[*Database](),
+		Generate: func(p *Profile, g *dag.Graph) Command {
+			return RenameDatabase{
+				Database: dag.Any[*Database](g),
+				Name:     p.randomName(databaseNames(g)...),
+			}
+		},
 	},
 
 	// ALTER TABLE ... https://github.com/cockroachdb/cockroach/blob/master/pkg/sql/parser/sql.y#L1878-L1888
-	reflect.TypeOf(DropColumn{}):               func(g *dag.Graph) Command { return DropColumn{dag.Nodes[*Column](g).Any()} },
-	reflect.TypeOf(DropForeignKeyConstraint{}): func(g *dag.Graph) Command { panic("not implemented") },
-	reflect.TypeOf(AddColumn{}): func(g *dag.Graph) Command {
-		return AddColumn{
-			Table:    dag.Nodes[*Table](g).Any(),
-			Name:     RandomString(),
-			Nullable: false,
-		}
+	reflect.TypeOf(DropColumn{}): {
+		Feasible: exists[*Column](),
+		Generate: func(p *Profile, g *dag.Graph) Command { return DropColumn{dag.Nodes[*Column](g).Any()} },
+	},
+	reflect.TypeOf(DropForeignKeyConstraint{}): {
+		// Not implemented.
+		Feasible: func(p *Profile, g *dag.Graph) bool { return false },
+		Generate: func(p *Profile, g *dag.Graph) Command { panic("not implemented") },
 	},
-	reflect.TypeOf(CreateForeignKeyConstraint{}): func(g *dag.Graph) Command {
+	reflect.TypeOf(AddColumn{}): {
+		Feasible: func(p *Profile, g *dag.Graph) bool {
+			return exists(hasRoomForColumns(p))(p, g)
+		},
+		Generate: func(p *Profile, g *dag.Graph) Command {
+			table := dag.Any(g, hasRoomForColumns(p))
+			return AddColumn{
+				Table:    table,
+				Name:     p.randomName(columnNames(table)...),
+				Nullable: false,
+			}
+		},
+	},
+	reflect.TypeOf(CreateForeignKeyConstraint{}): {
 		// TODO this is pretty constrainted.
-
-		// Find any column that has a unique index.
-		to := dag.Nodes[*Index](g, func(i *Index) bool {
-			return i.Unique && len(i.Columns()) == 1
-		}).Any().Columns()[0]
-
-		// Find any other column that isn't from the same table (could be
-		// literally any other column though).
-		from := dag.Nodes[*Column](g, func(c *Column) bool {
-			return c.Table().Schema().Database() == to.Table().Schema().Database() && c.Table() != to.Table()
-		}).Any()
-
-		return CreateForeignKeyConstraint{
-			Name: RandomString(),
-			From: from,
-			To:   to,
-		}
+		Feasible: func(p *Profile, g *dag.Graph) bool {
+			return under(len(dag.Nodes[*ForeignKeyConstraint](g)), p.Limits.ForeignKeys) && len(fkTargets(g)) > 0
+		},
+		Generate: func(p *Profile, g *dag.Graph) Command {
+			to := fkTargets(g).Any()
+			from := fkSources(g, to).Any()
+			return CreateForeignKeyConstraint{
+				Name: p.randomName(constraintNames(from.Table())...),
+				From: from,
+				To:   to,
+			}
+		},
 	},
 }
 
-func GenerateCommand(g *dag.Graph) Command {
-	// TODO this is pretty memory hungry, there's certainly a better way to do
-	// this. I'm just a bit lazy.
-	// https://en.wikipedia.org/wiki/Alias_method
-	var weighted []reflect.Type
+// GenerateCommand returns a random Command that may be executed against the
+// provided state. Command types are chosen proportionally to their weight
+// within the profile from the set of types that are currently Feasible.
+func GenerateCommand(p *Profile, g *dag.Graph) (Command, error) {
+	var types []reflect.Type
+	var weights []int
 	for _, cmd := range AllCommands {
 		t := reflect.TypeOf(cmd)
 
-		weight, ok := Weights[t]
-		if !ok {
-			weight = 1
+		weight := p.Weight(cmd)
+		if weight <= 0 || !Generators[t].Feasible(p, g) {
+			continue
 		}
 
-		for i := 0; i < weight; i++ {
-			weighted = append(weighted, t)
-		}
+		types = append(types, t)
+		weights = append(weights, weight)
 	}
 
-	rand.Shuffle(len(weighted), func(i, j int) {
-		weighted[i], weighted[j] = weighted[j], weighted[i]
-	})
-
-	generate := func(t reflect.Type) (cmd Command, ok bool) {
-		defer func() {
-			if err := recover(); err != nil {
-				log.Printf("failed to generate %s: %v", t, err)
-			}
-		}()
-		cmd = Generators[t](g)
-		return cmd, cmd != nil
+	if len(types) == 0 {
+		return nil, errors.WithStack(ErrNoFeasibleCommands)
 	}
 
-	// Try to generate up to 10 steps before giving up. This should probably be
-	// a weighted selection that eliminates options as it goes but this is
-	// close enough for now.
-	for _, t := range weighted[:10] {
-		if cmd, ok := generate(t); ok {
-			// A bit of sanity checking as we can't statically verify this too
-			// well.
-			if reflect.TypeOf(cmd) != t {
-				panic(errors.Newf("generator for %v returned %T", t, cmd))
-			}
-			return cmd
-		}
+	t := types[newAliasTable(weights).Sample()]
+	cmd := Generators[t].Generate(p, g)
+
+	// A bit of sanity checking as we can't statically verify this too well.
+	if reflect.TypeOf(cmd) != t {
+		return nil, errors.Newf("generator for %v returned %T", t, cmd)
 	}
 
-	panic("Failed to generate any valid steps after 10 attempts")
+	return cmd, nil
 }
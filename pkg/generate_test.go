@@ -0,0 +1,42 @@
+package pkg_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/chrisseto/scwl/pkg"
+	"github.com/chrisseto/scwl/pkg/dag"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateCommand(t *testing.T) {
+	g := dag.New()
+
+	defaultdb := g.AddNode("1", &pkg.Database{Name: "defaultdb"}).(*pkg.Database)
+	public := g.AddNode("2", &pkg.Schema{Name: "public"}).(*pkg.Schema)
+	users := g.AddNode("3", &pkg.Table{Name: "users"}).(*pkg.Table)
+
+	g.AddEdge(defaultdb, public)
+	g.AddEdge(public, users)
+
+	// Without any columns, indexes or non-public schemas, only a subset of
+	// commands may be generated.
+	feasible := map[reflect.Type]bool{
+		reflect.TypeOf(pkg.AddColumn{}):      true,
+		reflect.TypeOf(pkg.CreateDatabase{}): true,
+		reflect.TypeOf(pkg.CreateSchema{}):   true,
+		reflect.TypeOf(pkg.CreateTable{}):    true,
+		reflect.TypeOf(pkg.RenameDatabase{}): true,
+		reflect.TypeOf(pkg.RenameTable{}):    true,
+	}
+
+	generated := map[reflect.Type]bool{}
+	for i := 0; i < 1000; i++ {
+		cmd, err := pkg.GenerateCommand(&pkg.DefaultProfile, g)
+		require.NoError(t, err)
+		require.Contains(t, feasible, reflect.TypeOf(cmd))
+		generated[reflect.TypeOf(cmd)] = true
+	}
+
+	require.Equal(t, feasible, generated)
+}
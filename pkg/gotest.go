@@ -0,0 +1,112 @@
+package pkg
+
+import (
+	"encoding/json"
+	"go/format"
+	"io"
+	"strings"
+	"text/template"
+
+	"github.com/cockroachdb/errors"
+)
+
+// GoTestOptions configures [Transcript.WriteGoTest].
+type GoTestOptions struct {
+	// Package is the package clause of the generated file.
+	Package string
+	// Name is the name of the generated test function, it must begin with
+	// Test.
+	Name string
+	// Version overrides the version of CockroachDB recorded in the
+	// transcript.
+	Version string
+}
+
+var goTestTemplate = template.Must(template.New("").Funcs(template.FuncMap{
+	"raw":     goRawString,
+	"command": CommandToString,
+}).Parse(`// Code generated by scwl export-test. DO NOT EDIT.
+
+package {{ .Package }}
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"testing"
+
+	"github.com/chrisseto/scwl/pkg"
+	"github.com/cockroachdb/cockroach-go/v2/testserver"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+// {{ .Name }} replays the following steps against CockroachDB {{ .Version }}:
+//
+{{- range $i, $step := .Transcript.Steps }}
+//	{{ $i }}: {{ command $step.Command }}
+{{- end }}
+func {{ .Name }}(t *testing.T) {
+	ts, err := testserver.NewTestServer(testserver.CustomVersionOpt({{ printf "%q" .Version }}))
+	require.NoError(t, err)
+	defer ts.Stop()
+
+	url := ts.PGURL()
+	url.Path = "system"
+	db, err := sqlx.Open("pgx", url.String())
+	require.NoError(t, err)
+	defer db.Close()
+
+	var transcript pkg.Transcript
+	require.NoError(t, json.Unmarshal([]byte({{ raw .JSON }}), &transcript))
+
+	sut := pkg.NewSUT(db, log.New(io.Discard, "", 0))
+	require.NoError(t, transcript.Run(context.Background(), sut))
+}
+`))
+
+// WriteGoTest writes a standalone Go test to w that starts a testserver at
+// the transcript's version and asserts that replaying the transcript's steps
+// results in the expected states.
+func (t *Transcript) WriteGoTest(w io.Writer, opts GoTestOptions) error {
+	if !strings.HasPrefix(opts.Name, "Test") {
+		return errors.Newf("test name %q must begin with Test", opts.Name)
+	}
+
+	if opts.Version == "" {
+		opts.Version = t.Version
+	}
+	if opts.Version == "" {
+		return errors.New("transcript does not record a version and no version was provided")
+	}
+
+	encoded, err := json.MarshalIndent(t, "", "\t")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	var b strings.Builder
+	if err := goTestTemplate.Execute(&b, struct {
+		GoTestOptions
+		Transcript *Transcript
+		JSON       string
+	}{opts, t, string(encoded)}); err != nil {
+		return errors.WithStack(err)
+	}
+
+	formatted, err := format.Source([]byte(b.String()))
+	if err != nil {
+		return errors.Wrap(err, "formatting generated test")
+	}
+
+	_, err = w.Write(formatted)
+	return errors.WithStack(err)
+}
+
+// goRawString renders s as a Go raw string literal. Backticks, which can't
+// appear within a raw string, are concatenated as interpreted strings.
+func goRawString(s string) string {
+	return "`" + strings.ReplaceAll(s, "`", "` + \"`\" + `") + "`"
+}
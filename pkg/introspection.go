@@ -0,0 +1,335 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/chrisseto/scwl/pkg/dag"
+	"github.com/cockroachdb/errors"
+)
+
+// Introspection is a source of the SUT's catalog from which its state may be
+// loaded.
+type Introspection string
+
+const (
+	// IntrospectionInternal is the default source used by State. It mixes
+	// system.namespace, crdb_internal and raw descriptors.
+	IntrospectionInternal Introspection = "crdb_internal"
+	// IntrospectionInformationSchema uses only information_schema.
+	IntrospectionInformationSchema Introspection = "information_schema"
+	// IntrospectionPGCatalog uses only pg_catalog.
+	IntrospectionPGCatalog Introspection = "pg_catalog"
+	// IntrospectionShowCreate uses SHOW DATABASES, SHOW SCHEMAS and parses
+	// the output of SHOW CREATE ALL TABLES.
+	IntrospectionShowCreate Introspection = "show_create"
+)
+
+// Introspections are all sources of the SUT's catalog.
+var Introspections = []Introspection{
+	IntrospectionInternal,
+	IntrospectionInformationSchema,
+	IntrospectionPGCatalog,
+	IntrospectionShowCreate,
+}
+
+// CrossChecker is implemented by Systems that have multiple sources of
+// their state.
+type CrossChecker interface {
+	// CrossCheck returns an error if any of the System's sources of state
+	// disagree with one another.
+	CrossCheck(context.Context) error
+}
+
+// virtualSchemas are the schemas present in every database that aren't
+// modeled.
+const virtualSchemas = `('crdb_internal', 'information_schema', 'pg_catalog', 'pg_extension')`
+
+// informationSchemaQueries load state from information_schema. Within
+// "".information_schema, the views span all databases. IDs are built from
+// quoted names as they'd otherwise be ambiguous.
+var informationSchemaQueries = Queries{
+	Databases: `SELECT DISTINCT
+		quote_ident(catalog_name) AS id,
+		catalog_name AS name
+	FROM "".information_schema.schemata
+	WHERE catalog_name != 'system'
+	ORDER BY name DESC
+	`,
+
+	Schemas: `SELECT
+		quote_ident(catalog_name) || '.' || quote_ident(schema_name) AS id,
+		quote_ident(catalog_name) AS database_id,
+		schema_name AS name
+	FROM "".information_schema.schemata
+	WHERE catalog_name != 'system' AND schema_name NOT IN ` + virtualSchemas + ` AND schema_name NOT LIKE 'pg_temp%'
+	ORDER BY name DESC
+	`,
+
+	Tables: `SELECT
+		quote_ident(table_catalog) || '.' || quote_ident(table_schema) || '.' || quote_ident(table_name) AS id,
+		quote_ident(table_catalog) || '.' || quote_ident(table_schema) AS schema_id,
+		table_name AS name
+	FROM "".information_schema.tables
+	WHERE table_catalog != 'system' AND table_type = 'BASE TABLE' AND table_schema NOT IN ` + virtualSchemas + `
+	ORDER BY name DESC
+	`,
+
+	Columns: `SELECT
+		quote_ident(table_catalog) || '.' || quote_ident(table_schema) || '.' || quote_ident(table_name) || '.cols.' || quote_ident(column_name) AS id,
+		quote_ident(table_catalog) || '.' || quote_ident(table_schema) || '.' || quote_ident(table_name) AS table_id,
+		column_name AS name
+	FROM "".information_schema.columns
+	WHERE table_catalog != 'system' AND is_hidden = 'NO' AND table_schema NOT IN ` + virtualSchemas + `
+	ORDER BY name DESC
+	`,
+
+	// information_schema.statistics has a row per column of each index.
+	Indexes: `SELECT DISTINCT
+		quote_ident(s.table_catalog) || '.' || quote_ident(s.table_schema) || '.' || quote_ident(s.table_name) || '.idxs.' || quote_ident(s.index_name) AS id,
+		quote_ident(s.table_catalog) || '.' || quote_ident(s.table_schema) || '.' || quote_ident(s.table_name) AS table_id,
+		s.non_unique = 'NO' AS "unique",
+		s.index_name AS name
+	FROM "".information_schema.statistics s
+	WHERE s.table_catalog != 'system' AND s.table_schema NOT IN ` + virtualSchemas + ` AND NOT EXISTS (
+		SELECT 1 FROM "".information_schema.table_constraints tc
+		WHERE tc.constraint_type = 'PRIMARY KEY'
+		AND tc.table_catalog = s.table_catalog
+		AND tc.table_schema = s.table_schema
+		AND tc.table_name = s.table_name
+		AND tc.constraint_name = s.index_name
+	)
+	ORDER BY name DESC
+	`,
+
+	ColumnsToIndexes: `SELECT
+		quote_ident(s.table_catalog) || '.' || quote_ident(s.table_schema) || '.' || quote_ident(s.table_name) || '.idxs.' || quote_ident(s.index_name) AS index_id,
+		quote_ident(s.table_catalog) || '.' || quote_ident(s.table_schema) || '.' || quote_ident(s.table_name) || '.cols.' || quote_ident(s.column_name) AS column_id
+	FROM "".information_schema.statistics s
+	WHERE s.table_catalog != 'system' AND s.table_schema NOT IN ` + virtualSchemas + `
+	AND s.storing = 'NO' AND s.implicit = 'NO' AND NOT EXISTS (
+		SELECT 1 FROM "".information_schema.table_constraints tc
+		WHERE tc.constraint_type = 'PRIMARY KEY'
+		AND tc.table_catalog = s.table_catalog
+		AND tc.table_schema = s.table_schema
+		AND tc.table_name = s.table_name
+		AND tc.constraint_name = s.index_name
+	)
+	ORDER BY s.index_name DESC, s.seq_in_index
+	`,
+
+	// For foreign keys, key_column_usage is the referencing column and
+	// constraint_column_usage is the referenced column.
+	ForeignKeyConstraints: `SELECT
+		quote_ident(ccu.table_catalog) || '.' || quote_ident(ccu.table_schema) || '.' || quote_ident(ccu.table_name) || '.cols.' || quote_ident(ccu.column_name) AS to_id,
+		quote_ident(kcu.table_catalog) || '.' || quote_ident(kcu.table_schema) || '.' || quote_ident(kcu.table_name) || '.cols.' || quote_ident(kcu.column_name) AS from_id,
+		rc.constraint_name AS name
+	FROM "".information_schema.referential_constraints rc
+	JOIN "".information_schema.key_column_usage kcu ON (
+		kcu.constraint_catalog = rc.constraint_catalog
+		AND kcu.constraint_schema = rc.constraint_schema
+		AND kcu.constraint_name = rc.constraint_name
+	)
+	JOIN "".information_schema.constraint_column_usage ccu ON (
+		ccu.constraint_catalog = rc.constraint_catalog
+		AND ccu.constraint_schema = rc.constraint_schema
+		AND ccu.constraint_name = rc.constraint_name
+	)
+	WHERE rc.constraint_catalog != 'system'
+	ORDER BY name DESC
+	`,
+}
+
+// pgCatalogQueries load state from pg_catalog. Unlike information_schema,
+// pg_catalog is scoped to a single database so each query is a UNION ALL
+// over databases. pg_attribute doesn't indicate whether a column is hidden,
+// so rowid is excluded by name; generated columns are never named rowid.
+func pgCatalogQueries(databases []string) Queries {
+	union := func(orderBy, tpl string) string {
+		parts := make([]string, len(databases))
+		for i, db := range databases {
+			parts[i] = fmt.Sprintf(tpl, QuoteIdentifier(db), QuoteLiteral(db))
+		}
+		return "SELECT * FROM (" + strings.Join(parts, " UNION ALL ") + ") ORDER BY " + orderBy
+	}
+
+	// In each template, %[1]s is the database's identifier and %[2]s is its
+	// name as a string literal, which prefixes IDs.
+	return Queries{
+		Databases: `SELECT
+			quote_ident(datname) AS id,
+			datname AS name
+		FROM pg_catalog.pg_database
+		WHERE datname != 'system'
+		ORDER BY name DESC
+		`,
+
+		Schemas: union("name DESC", `SELECT
+			quote_ident(%[2]s) || '.' || quote_ident(nspname) AS id,
+			quote_ident(%[2]s) AS database_id,
+			nspname AS name
+		FROM %[1]s.pg_catalog.pg_namespace
+		WHERE nspname NOT IN `+virtualSchemas+` AND nspname NOT LIKE 'pg_temp%%'`),
+
+		Tables: union("name DESC", `SELECT
+			quote_ident(%[2]s) || '.' || quote_ident(n.nspname) || '.' || quote_ident(c.relname) AS id,
+			quote_ident(%[2]s) || '.' || quote_ident(n.nspname) AS schema_id,
+			c.relname AS name
+		FROM %[1]s.pg_catalog.pg_class c
+		JOIN %[1]s.pg_catalog.pg_namespace n ON c.relnamespace = n.oid
+		WHERE c.relkind = 'r' AND n.nspname NOT IN `+virtualSchemas),
+
+		Columns: union("name DESC", `SELECT
+			quote_ident(%[2]s) || '.' || quote_ident(n.nspname) || '.' || quote_ident(c.relname) || '.cols.' || quote_ident(a.attname) AS id,
+			quote_ident(%[2]s) || '.' || quote_ident(n.nspname) || '.' || quote_ident(c.relname) AS table_id,
+			a.attname AS name
+		FROM %[1]s.pg_catalog.pg_attribute a
+		JOIN %[1]s.pg_catalog.pg_class c ON a.attrelid = c.oid
+		JOIN %[1]s.pg_catalog.pg_namespace n ON c.relnamespace = n.oid
+		WHERE c.relkind = 'r' AND a.attnum > 0 AND NOT a.attisdropped AND a.attname != 'rowid'
+		AND n.nspname NOT IN `+virtualSchemas),
+
+		Indexes: union("name DESC", `SELECT
+			quote_ident(%[2]s) || '.' || quote_ident(n.nspname) || '.' || quote_ident(t.relname) || '.idxs.' || quote_ident(ic.relname) AS id,
+			quote_ident(%[2]s) || '.' || quote_ident(n.nspname) || '.' || quote_ident(t.relname) AS table_id,
+			i.indisunique AS "unique",
+			ic.relname AS name
+		FROM %[1]s.pg_catalog.pg_index i
+		JOIN %[1]s.pg_catalog.pg_class ic ON i.indexrelid = ic.oid
+		JOIN %[1]s.pg_catalog.pg_class t ON i.indrelid = t.oid
+		JOIN %[1]s.pg_catalog.pg_namespace n ON t.relnamespace = n.oid
+		WHERE NOT i.indisprimary AND n.nspname NOT IN `+virtualSchemas),
+
+		// Only the first indnkeyatts of indkey are key columns, the
+		// remainder are stored.
+		ColumnsToIndexes: union("index_id DESC", `SELECT
+			quote_ident(%[2]s) || '.' || quote_ident(n.nspname) || '.' || quote_ident(t.relname) || '.idxs.' || quote_ident(ic.relname) AS index_id,
+			quote_ident(%[2]s) || '.' || quote_ident(n.nspname) || '.' || quote_ident(t.relname) || '.cols.' || quote_ident(a.attname) AS column_id
+		FROM %[1]s.pg_catalog.pg_index i
+		JOIN %[1]s.pg_catalog.pg_class ic ON i.indexrelid = ic.oid
+		JOIN %[1]s.pg_catalog.pg_class t ON i.indrelid = t.oid
+		JOIN %[1]s.pg_catalog.pg_namespace n ON t.relnamespace = n.oid
+		JOIN LATERAL unnest(i.indkey::INT2[]) WITH ORDINALITY AS k (attnum, ord) ON k.ord <= i.indnkeyatts
+		JOIN %[1]s.pg_catalog.pg_attribute a ON a.attrelid = t.oid AND a.attnum = k.attnum
+		WHERE NOT i.indisprimary AND n.nspname NOT IN `+virtualSchemas),
+
+		ForeignKeyConstraints: union("name DESC", `SELECT
+			quote_ident(%[2]s) || '.' || quote_ident(tn.nspname) || '.' || quote_ident(tc.relname) || '.cols.' || quote_ident(ta.attname) AS to_id,
+			quote_ident(%[2]s) || '.' || quote_ident(fn.nspname) || '.' || quote_ident(fc.relname) || '.cols.' || quote_ident(fa.attname) AS from_id,
+			con.conname AS name
+		FROM %[1]s.pg_catalog.pg_constraint con
+		JOIN %[1]s.pg_catalog.pg_class fc ON con.conrelid = fc.oid
+		JOIN %[1]s.pg_catalog.pg_namespace fn ON fc.relnamespace = fn.oid
+		JOIN %[1]s.pg_catalog.pg_attribute fa ON fa.attrelid = fc.oid AND fa.attnum = con.conkey[1]
+		JOIN %[1]s.pg_catalog.pg_class tc ON con.confrelid = tc.oid
+		JOIN %[1]s.pg_catalog.pg_namespace tn ON tc.relnamespace = tn.oid
+		JOIN %[1]s.pg_catalog.pg_attribute ta ON ta.attrelid = tc.oid AND ta.attnum = con.confkey[1]
+		WHERE con.contype = 'f'`),
+	}
+}
+
+// StateFrom loads the state of the SUT from source.
+func (o *sut) StateFrom(ctx context.Context, source Introspection) (*dag.Graph, error) {
+	switch source {
+	case IntrospectionInternal:
+		return o.State(ctx)
+
+	case IntrospectionInformationSchema:
+		return loadState(ctx, o.conn, informationSchemaQueries)
+
+	case IntrospectionPGCatalog:
+		var databases []string
+		if err := o.conn.SelectContext(ctx, &databases, `SELECT datname FROM pg_catalog.pg_database WHERE datname != 'system'`); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		// pgCatalogQueries can't express a UNION ALL over zero databases.
+		if len(databases) == 0 {
+			return dag.New(), nil
+		}
+		return loadState(ctx, o.conn, pgCatalogQueries(databases))
+
+	case IntrospectionShowCreate:
+		return o.showCreateState(ctx)
+
+	default:
+		return nil, errors.Newf("unknown introspection %q", source)
+	}
+}
+
+// showCreateState loads the state of the SUT from SHOW statements. SHOW
+// CREATE ALL TABLES is scoped to the current database, so it's run on a
+// dedicated connection.
+func (o *sut) showCreateState(ctx context.Context) (*dag.Graph, error) {
+	conn, err := o.conn.Connx(ctx)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer func() {
+		// Restore the connection's database before returning it to the pool,
+		// which may fail if the connection is broken.
+		_, _ = conn.ExecContext(ctx, `SET database = system`)
+		_ = conn.Close()
+	}()
+
+	var databases []string
+	if err := conn.SelectContext(ctx, &databases, `SELECT database_name FROM [SHOW DATABASES] WHERE database_name != 'system' ORDER BY database_name DESC`); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	g := dag.New()
+	for _, name := range databases {
+		db := g.AddNode(QuoteIdentifier(name), &Database{Name: name}).(*Database)
+
+		var schemas []string
+		if err := conn.SelectContext(ctx, &schemas, `SELECT schema_name FROM [SHOW SCHEMAS FROM `+QuoteIdentifier(name)+`] WHERE schema_name NOT IN `+virtualSchemas+` AND schema_name NOT LIKE 'pg_temp%' ORDER BY schema_name DESC`); err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		for _, schema := range schemas {
+			g.AddEdge(db, g.AddNode(QuoteIdentifier(name)+"."+QuoteIdentifier(schema), &Schema{Name: schema}))
+		}
+
+		if _, err := conn.ExecContext(ctx, `SET database = `+QuoteIdentifier(name)); err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		var stmts []string
+		if err := conn.SelectContext(ctx, &stmts, `SELECT create_statement FROM [SHOW CREATE ALL TABLES]`); err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		if err := LoadCreateStatements(g, db, stmts); err != nil {
+			return nil, errors.Wrapf(err, "database %s", name)
+		}
+	}
+
+	return g, nil
+}
+
+// CrossCheck implements [CrossChecker]. The state from each Introspection is
+// compared to that of State, regardless of the order that nodes were loaded
+// in.
+func (o *sut) CrossCheck(ctx context.Context) error {
+	want, err := o.State(ctx)
+	if err != nil {
+		return err
+	}
+
+	var disagreements []string
+	for _, source := range Introspections[1:] {
+		got, err := o.StateFrom(ctx, source)
+		if err != nil {
+			return errors.Wrapf(err, "loading state from %s", source)
+		}
+
+		for _, d := range describeMismatched(Mismatched(want, got)) {
+			disagreements = append(disagreements, fmt.Sprintf("%s: %s", source, d))
+		}
+	}
+
+	if len(disagreements) > 0 {
+		return errors.Newf("introspection sources disagree with %s:\n\t%s", IntrospectionInternal, strings.Join(disagreements, "\n\t"))
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package pkg
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/chrisseto/scwl/pkg/dag"
+	"github.com/cockroachdb/errors"
+)
+
+// LogicTestOptions configures [Transcript.WriteLogicTest].
+type LogicTestOptions struct {
+	// CheckEveryStep emits query blocks asserting the expected state after
+	// every step rather than only after the final step.
+	CheckEveryStep bool
+}
+
+// WriteLogicTest writes the transcript to w as a CockroachDB logictest, see
+// pkg/sql/logictest. Each step is emitted as a statement with the outcome
+// observed by the oracle, preceded by a SET whenever the step's schema changer
+// differs from the prior step's. The expected state is asserted with query blocks
+// over SHOW TABLES, SHOW COLUMNS and SHOW INDEXES.
+func (t *Transcript) WriteLogicTest(w io.Writer, opts LogicTestOptions) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# Code generated by scwl export-logictest. DO NOT EDIT.\n")
+	if t.Version != "" {
+		fmt.Fprintf(&b, "# Recorded against CockroachDB %s.\n", t.Version)
+	}
+	if t.ReferenceVersion != "" {
+		fmt.Fprintf(&b, "# Expected outcomes were recorded against CockroachDB %s.\n", t.ReferenceVersion)
+	}
+
+	schemaChanger := SchemaChangerDefault
+	for i, step := range t.Steps {
+		fmt.Fprintf(&b, "\n# Step %d: %s\n", i, CommandToString(step.Command))
+
+		if step.SchemaChanger != schemaChanger {
+			schemaChanger = step.SchemaChanger
+			if schemaChanger == SchemaChangerDefault {
+				fmt.Fprintf(&b, "statement ok\nRESET use_declarative_schema_changer\n\n")
+			} else {
+				fmt.Fprintf(&b, "statement ok\nSET use_declarative_schema_changer = %s\n\n", QuoteLiteral(schemaChanger.setting()))
+			}
+		}
+
+		stmt := logicTestStatement(AsDDL(step.Command))
+		if step.Error == "" {
+			fmt.Fprintf(&b, "statement ok\n%s\n", stmt)
+		} else {
+			fmt.Fprintf(&b, "# oracle: %s\n", strings.ReplaceAll(step.Error, "\n", " "))
+			fmt.Fprintf(&b, "statement error %s\n%s\n", errorPattern(step.Error), stmt)
+		}
+
+		if opts.CheckEveryStep || i == len(t.Steps)-1 {
+			writeStateQueries(&b, step.Expected)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return errors.WithStack(err)
+}
+
+// errorPattern returns a regular expression matching the error message msg,
+// as recorded in a Step, for use in a statement error directive. pgx's
+// "ERROR: " prefix and "(SQLSTATE XXXXX)" suffix are dropped as logictests
+// match against the message alone. Only the first line of msg is matched.
+func errorPattern(msg string) string {
+	msg, _, _ = strings.Cut(msg, "\n")
+	msg = strings.TrimPrefix(msg, "ERROR: ")
+	if i := strings.LastIndex(msg, " (SQLSTATE "); i >= 0 {
+		msg = msg[:i]
+	}
+	return regexp.QuoteMeta(msg)
+}
+
+// logicTestStatement joins the lines of the multi-line DDL templates into a
+// single line. A blank line terminates a statement within a logictest.
+func logicTestStatement(ddl string) string {
+	var lines []string
+	for _, line := range strings.Split(ddl, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, " ")
+}
+
+// writeStateQueries writes query blocks to b that assert the tables, columns
+// and secondary indexes of state. Primary indexes and hidden or implicit
+// columns, such as rowid, are excluded as they aren't modeled.
+func writeStateQueries(b *strings.Builder, state *dag.Graph) {
+	for _, db := range sortedByName(dag.Nodes[*Database](state), func(d *Database) string { return d.Name }) {
+		var rows [][]string
+		for _, schema := range db.Schemas() {
+			for _, table := range schema.Tables() {
+				rows = append(rows, []string{escapeWhitespace(schema.Name), escapeWhitespace(table.Name)})
+			}
+		}
+
+		writeQuery(b, "TT", fmt.Sprintf(
+			"SELECT %s, %s FROM [SHOW TABLES FROM %s]",
+			escapeWhitespaceSQL("schema_name"), escapeWhitespaceSQL("table_name"), QuotedName(db),
+		), rows)
+	}
+
+	for _, table := range sortedByName(dag.Nodes[*Table](state), func(t *Table) string { return FullyQualifiedName(t) }) {
+		var rows [][]string
+		for _, column := range table.Columns() {
+			rows = append(rows, []string{escapeWhitespace(column.Name)})
+		}
+
+		writeQuery(b, "T", fmt.Sprintf(
+			"SELECT %s FROM [SHOW COLUMNS FROM %s] WHERE NOT is_hidden",
+			escapeWhitespaceSQL("column_name"), QuotedName(table),
+		), rows)
+
+		rows = nil
+		for _, index := range table.Indexes() {
+			for _, column := range index.Columns() {
+				rows = append(rows, []string{escapeWhitespace(index.Name), escapeWhitespace(column.Name), fmt.Sprint(!index.Unique)})
+			}
+		}
+
+		writeQuery(b, "TTB", fmt.Sprintf(
+			"SELECT %[2]s, %[3]s, non_unique FROM [SHOW INDEXES FROM %[1]s] WHERE NOT storing AND NOT implicit AND index_name NOT IN (SELECT constraint_name FROM [SHOW CONSTRAINTS FROM %[1]s] WHERE constraint_type = 'PRIMARY KEY')",
+			QuotedName(table), escapeWhitespaceSQL("index_name"), escapeWhitespaceSQL("column_name"),
+		), rows)
+	}
+}
+
+// escapeWhitespace backslash escapes backslashes and whitespace within s.
+// Logictests compare results by whitespace separated fields, so values
+// containing whitespace would otherwise be ambiguous. eg: "e mail" is written
+// as "e\smail". It must agree with escapeWhitespaceSQL.
+func escapeWhitespace(s string) string {
+	return strings.NewReplacer(`\`, `\\`, " ", `\s`, "\t", `\t`, "\n", `\n`).Replace(s)
+}
+
+// escapeWhitespaceSQL returns a SQL expression that applies escapeWhitespace
+// to the STRING expression expr.
+func escapeWhitespaceSQL(expr string) string {
+	return fmt.Sprintf(`replace(replace(replace(replace(%s, '\', '\\'), ' ', '\s'), e'\t', '\t'), e'\n', '\n')`, expr)
+}
+
+// writeQuery writes a rowsort query block to b. The separator is always
+// written so that an empty result is asserted rather than ignored. Values
+// are expected to have been escaped by escapeWhitespace.
+func writeQuery(b *strings.Builder, types, query string, rows [][]string) {
+	fmt.Fprintf(b, "\nquery %s rowsort\n%s\n----\n", types, query)
+	for _, row := range rows {
+		for i := range row {
+			if row[i] == "" {
+				row[i] = "·"
+			}
+		}
+		fmt.Fprintf(b, "%s\n", strings.Join(row, "  "))
+	}
+}
+
+func sortedByName[T any](items []T, name func(T) string) []T {
+	sort.SliceStable(items, func(i, j int) bool {
+		return name(items[i]) < name(items[j])
+	})
+	return items
+}
@@ -0,0 +1,71 @@
+package pkg_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/chrisseto/scwl/pkg"
+	"github.com/chrisseto/scwl/pkg/dag"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteLogicTest(t *testing.T) {
+	g := dag.New()
+
+	defaultdb := g.AddNode("1", &pkg.Database{Name: "defaultdb"}).(*pkg.Database)
+	public := g.AddNode("2", &pkg.Schema{Name: "public"}).(*pkg.Schema)
+	users := g.AddNode("3", &pkg.Table{Name: "users"}).(*pkg.Table)
+	id := g.AddNode("4", &pkg.Column{Name: "id"}).(*pkg.Column)
+	email := g.AddNode("5", &pkg.Column{Name: "e mail"}).(*pkg.Column)
+	idx := g.AddNode("6", &pkg.Index{Name: "users_email_key", Unique: true}).(*pkg.Index)
+
+	g.AddEdge(defaultdb, public)
+	g.AddEdge(public, users)
+	g.AddEdge(users, id)
+	g.AddEdge(users, email)
+	g.AddEdge(users, idx)
+	g.AddEdge(idx, email)
+
+	transcript := &pkg.Transcript{
+		Version: "v23.1.0",
+		Initial: g,
+		Steps: []pkg.Step{
+			{Command: pkg.CreateIndex{Table: users, Columns: []*pkg.Column{email}, Name: "users_email_key", Unique: true}, Expected: g},
+			{Command: pkg.CreateIndex{Table: users, Columns: []*pkg.Column{id}, Name: "users_email_key"}, Expected: g, Error: `ERROR: relation "defaultdb.public.users_email_key" already exists (SQLSTATE 42P07)`, SchemaChanger: pkg.SchemaChangerLegacy},
+		},
+	}
+
+	var b strings.Builder
+	require.NoError(t, transcript.WriteLogicTest(&b, pkg.LogicTestOptions{}))
+	require.Equal(t, `# Code generated by scwl export-logictest. DO NOT EDIT.
+# Recorded against CockroachDB v23.1.0.
+
+# Step 0: pkg.CreateIndex{Table: pkg.ByFQN[*pkg.Table](g, "defaultdb.public.users"), Columns: []*pkg.Column{pkg.ByFQN[*pkg.Column](g, "defaultdb.public.users.cols.e mail")}, Name: "users_email_key", Unique: true}
+statement ok
+CREATE UNIQUE INDEX "users_email_key"  ON "defaultdb"."public"."users" ( "e mail" )
+
+# Step 1: pkg.CreateIndex{Table: pkg.ByFQN[*pkg.Table](g, "defaultdb.public.users"), Columns: []*pkg.Column{pkg.ByFQN[*pkg.Column](g, "defaultdb.public.users.cols.id")}, Name: "users_email_key", Unique: false}
+statement ok
+SET use_declarative_schema_changer = 'off'
+
+# oracle: ERROR: relation "defaultdb.public.users_email_key" already exists (SQLSTATE 42P07)
+statement error relation "defaultdb\.public\.users_email_key" already exists
+CREATE  INDEX "users_email_key"  ON "defaultdb"."public"."users" ( "id" )
+
+query TT rowsort
+SELECT replace(replace(replace(replace(schema_name, '\', '\\'), ' ', '\s'), e'\t', '\t'), e'\n', '\n'), replace(replace(replace(replace(table_name, '\', '\\'), ' ', '\s'), e'\t', '\t'), e'\n', '\n') FROM [SHOW TABLES FROM "defaultdb"]
+----
+public  users
+
+query T rowsort
+SELECT replace(replace(replace(replace(column_name, '\', '\\'), ' ', '\s'), e'\t', '\t'), e'\n', '\n') FROM [SHOW COLUMNS FROM "defaultdb"."public"."users"] WHERE NOT is_hidden
+----
+id
+e\smail
+
+query TTB rowsort
+SELECT replace(replace(replace(replace(index_name, '\', '\\'), ' ', '\s'), e'\t', '\t'), e'\n', '\n'), replace(replace(replace(replace(column_name, '\', '\\'), ' ', '\s'), e'\t', '\t'), e'\n', '\n'), non_unique FROM [SHOW INDEXES FROM "defaultdb"."public"."users"] WHERE NOT storing AND NOT implicit AND index_name NOT IN (SELECT constraint_name FROM [SHOW CONSTRAINTS FROM "defaultdb"."public"."users"] WHERE constraint_type = 'PRIMARY KEY')
+----
+users_email_key  e\smail  false
+`, b.String())
+}
@@ -9,33 +9,36 @@ import (
 	"github.com/jmoiron/sqlx"
 )
 
+// oracleSchema models the expected state of the SUT. Ids are the
+// FullyQualifiedName of their element. Names are escaped, as by escapeName,
+// with replace(replace(name, '\', '\\'), '.', '\.').
 const oracleSchema = `
 CREATE TABLE databases (
-	id TEXT PRIMARY KEY AS (name) STORED,
+	id TEXT PRIMARY KEY AS (replace(replace(name, '\', '\\'), '.', '\.')) STORED,
 	name TEXT UNIQUE NOT NULL
 );
 
 CREATE TABLE schemas (
-	id TEXT PRIMARY KEY AS (database_id || '.' || name) STORED,
+	id TEXT PRIMARY KEY AS (database_id || '.' || replace(replace(name, '\', '\\'), '.', '\.')) STORED,
 	database_id TEXT NOT NULL references databases(id) ON DELETE CASCADE ON UPDATE CASCADE,
 	name TEXT NOT NULL
 );
 
 CREATE TABLE tables (
-	id TEXT PRIMARY KEY AS (schema_id || '.' || name) STORED,
+	id TEXT PRIMARY KEY AS (schema_id || '.' || replace(replace(name, '\', '\\'), '.', '\.')) STORED,
 	schema_id TEXT NOT NULL REFERENCES schemas(id) ON DELETE CASCADE ON UPDATE CASCADE,
 	name TEXT NOT NULL
 );
 
 CREATE TABLE columns (
-	id TEXT PRIMARY KEY AS (table_id || '.cols.' || name) STORED,
+	id TEXT PRIMARY KEY AS (table_id || '.cols.' || replace(replace(name, '\', '\\'), '.', '\.')) STORED,
 	table_id TEXT NOT NULL REFERENCES tables(id) ON DELETE CASCADE ON UPDATE CASCADE,
 	name TEXT NOT NULL,
 	nullable BOOL NOT NULL
 );
 
 CREATE TABLE indexes (
-	id TEXT PRIMARY KEY AS (table_id || '.idxs.' || name) STORED,
+	id TEXT PRIMARY KEY AS (table_id || '.idxs.' || replace(replace(name, '\', '\\'), '.', '\.')) STORED,
 	table_id TEXT NOT NULL REFERENCES tables(id) ON DELETE CASCADE ON UPDATE CASCADE,
 	name TEXT NOT NULL,
 	"unique" BOOL NOT NULL
@@ -0,0 +1,197 @@
+package pkg
+
+import (
+	"bytes"
+	_ "embed"
+	"io"
+	"reflect"
+	"sort"
+
+	"github.com/cockroachdb/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Profile configures the shape of a generated workload.
+type Profile struct {
+	Name string `yaml:"name"`
+	// Weights maps the name of a Command type, eg: CreateTable, to the
+	// relative likelihood of it being generated. Commands without a weight
+	// default to 1. A weight of 0 disables a Command.
+	Weights map[string]int `yaml:"weights"`
+	// Limits caps the number of objects that will be created.
+	Limits Limits `yaml:"limits"`
+	// Features toggles optional behaviors of generated Commands.
+	Features Features `yaml:"features"`
+	// SchemaChanger selects the schema changer that the SUT executes Commands
+	// with, see [SchemaChanger].
+	SchemaChanger SchemaChanger `yaml:"schema_changer"`
+}
+
+// Limits caps the number of objects that will be created by a workload. A
+// limit of 0 is unlimited.
+type Limits struct {
+	// Databases is the total number of databases, including defaultdb and
+	// postgres.
+	Databases int `yaml:"databases"`
+	// Schemas is the total number of schemas, including public schemas.
+	Schemas int `yaml:"schemas"`
+	// Tables is the total number of tables.
+	Tables int `yaml:"tables"`
+	// Columns is the number of columns per table.
+	Columns int `yaml:"columns"`
+	// Indexes is the number of secondary indexes per table.
+	Indexes int `yaml:"indexes"`
+	// ForeignKeys is the total number of foreign key constraints.
+	ForeignKeys int `yaml:"foreign_keys"`
+}
+
+// Features toggles optional behaviors of generated Commands.
+type Features struct {
+	// UniqueIndexes permits CreateIndex to create UNIQUE indexes.
+	UniqueIndexes bool `yaml:"unique_indexes"`
+	// MultiColumnIndexes permits CreateIndex to create indexes on more than
+	// one column.
+	MultiColumnIndexes bool `yaml:"multi_column_indexes"`
+	// AdversarialNames generates identifiers that are reserved keywords,
+	// unusually long or contain mixed case, quotes, dots, unicode or
+	// whitespace.
+	AdversarialNames bool `yaml:"adversarial_names"`
+}
+
+// Weight returns the weight of cmd within p.
+func (p *Profile) Weight(cmd Command) int {
+	if w, ok := p.Weights[reflect.TypeOf(cmd).Name()]; ok {
+		return w
+	}
+	return 1
+}
+
+// Validate returns an error if p references unknown Commands or schema
+// changers or contains negative weights or limits.
+func (p *Profile) Validate() error {
+	known := map[string]bool{}
+	for _, cmd := range AllCommands {
+		known[reflect.TypeOf(cmd).Name()] = true
+	}
+
+	for name, weight := range p.Weights {
+		if !known[name] {
+			return errors.Newf("profile %q: unknown command %q", p.Name, name)
+		}
+		if weight < 0 {
+			return errors.Newf("profile %q: negative weight for %q", p.Name, name)
+		}
+	}
+
+	if err := p.SchemaChanger.Validate(); err != nil {
+		return errors.Wrapf(err, "profile %q", p.Name)
+	}
+
+	v := reflect.ValueOf(p.Limits)
+	for i := 0; i < v.NumField(); i++ {
+		if v.Field(i).Int() < 0 {
+			return errors.Newf("profile %q: negative limit for %s", p.Name, v.Type().Field(i).Name)
+		}
+	}
+
+	return nil
+}
+
+func (p *Profile) clone() *Profile {
+	c := *p
+	c.Weights = make(map[string]int, len(p.Weights))
+	for k, v := range p.Weights {
+		c.Weights[k] = v
+	}
+	return &c
+}
+
+// DefaultProfile is the base that all loaded profiles are layered on top of.
+// Fields that a loaded profile doesn't specify retain the values from
+// DefaultProfile.
+var DefaultProfile = Profile{
+	Name: "default",
+	Weights: map[string]int{
+		"CreateIndex":  3,
+		"AddColumn":    3,
+		"CreateTable":  2,
+		"DropDatabase": 0,
+		"DropColumn":   0,
+		"DropTable":    0,
+		"DropSchema":   0,
+	},
+	Limits: Limits{
+		Databases: 5,
+		Schemas:   3,
+	},
+	Features: Features{
+		UniqueIndexes:      true,
+		MultiColumnIndexes: true,
+	},
+}
+
+//go:embed profiles.yaml
+var builtinProfiles []byte
+
+// BuiltinProfiles are the profiles that ship with scwl, keyed by name. It
+// always contains DefaultProfile.
+var BuiltinProfiles = func() map[string]*Profile {
+	profiles, err := LoadProfiles(bytes.NewReader(builtinProfiles))
+	if err != nil {
+		panic(err)
+	}
+
+	out := map[string]*Profile{DefaultProfile.Name: &DefaultProfile}
+	for _, p := range profiles {
+		out[p.Name] = p
+	}
+	return out
+}()
+
+// ProfileNames returns the sorted names of all profiles in profiles.
+func ProfileNames(profiles map[string]*Profile) []string {
+	var names []string
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoadProfiles decodes a YAML, or JSON, list of profiles from r. Each profile
+// is layered on top of DefaultProfile. Usage:
+//
+//	# profiles.yaml
+//	- name: rename-storm
+//	  weights:
+//	    RenameTable: 5
+//	  limits:
+//	    tables: 20
+func LoadProfiles(r io.Reader) ([]*Profile, error) {
+	var nodes []yaml.Node
+	if err := yaml.NewDecoder(r).Decode(&nodes); err != nil {
+		return nil, errors.Wrap(err, "decoding profiles")
+	}
+
+	profiles := make([]*Profile, len(nodes))
+	for i := range nodes {
+		p := DefaultProfile.clone()
+		p.Name = ""
+
+		if err := nodes[i].Decode(p); err != nil {
+			return nil, errors.Wrapf(err, "decoding profile %d", i)
+		}
+
+		if p.Name == "" {
+			return nil, errors.Newf("profile %d is missing a name", i)
+		}
+
+		if err := p.Validate(); err != nil {
+			return nil, err
+		}
+
+		profiles[i] = p
+	}
+
+	return profiles, nil
+}
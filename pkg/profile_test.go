@@ -0,0 +1,54 @@
+package pkg_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/chrisseto/scwl/pkg"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadProfiles(t *testing.T) {
+	require.Equal(t, []string{"adversarial-names", "default", "drop-cascade", "fk-heavy", "index-churn", "mixed-schema-changers", "rename-storm"}, pkg.ProfileNames(pkg.BuiltinProfiles))
+
+	profiles, err := pkg.LoadProfiles(strings.NewReader(`
+- name: tiny
+  weights:
+    RenameTable: 7
+  limits:
+    tables: 2
+  features:
+    unique_indexes: false
+`))
+	require.NoError(t, err)
+	require.Len(t, profiles, 1)
+
+	tiny := profiles[0]
+	require.Equal(t, "tiny", tiny.Name)
+	require.Equal(t, 7, tiny.Weight(pkg.RenameTable{}))
+	// Unspecified fields are inherited from the default profile.
+	require.Equal(t, 3, tiny.Weight(pkg.CreateIndex{}))
+	require.Equal(t, 1, tiny.Weight(pkg.RenameSchema{}))
+	require.Equal(t, pkg.Limits{Databases: 5, Schemas: 3, Tables: 2}, tiny.Limits)
+	require.Equal(t, pkg.Features{UniqueIndexes: false, MultiColumnIndexes: true}, tiny.Features)
+
+	// Loading must not modify the default profile.
+	require.Equal(t, 0, pkg.DefaultProfile.Limits.Tables)
+	require.Equal(t, 0, pkg.DefaultProfile.Weight(pkg.DropTable{}))
+
+	// JSON is accepted as well.
+	profiles, err = pkg.LoadProfiles(strings.NewReader(`[{"name": "json", "weights": {"DropTable": 2}}]`))
+	require.NoError(t, err)
+	require.Equal(t, 2, profiles[0].Weight(pkg.DropTable{}))
+
+	_, err = pkg.LoadProfiles(strings.NewReader(`[{"name": "bad", "weights": {"DropEverything": 1}}]`))
+	require.EqualError(t, err, `profile "bad": unknown command "DropEverything"`)
+
+	require.Equal(t, pkg.SchemaChangerRandom, pkg.BuiltinProfiles["mixed-schema-changers"].SchemaChanger)
+
+	_, err = pkg.LoadProfiles(strings.NewReader(`[{"name": "bad", "schema_changer": "newest"}]`))
+	require.EqualError(t, err, `profile "bad": unknown schema changer "newest"`)
+
+	_, err = pkg.LoadProfiles(strings.NewReader(`[{"weights": {"DropTable": 1}}]`))
+	require.EqualError(t, err, `profile 0 is missing a name`)
+}
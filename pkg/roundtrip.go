@@ -0,0 +1,170 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/chrisseto/scwl/pkg/dag"
+	"github.com/cockroachdb/errors"
+)
+
+// RoundTripper is implemented by Systems whose state may be dumped as DDL.
+type RoundTripper interface {
+	// RoundTrip recreates each database from its DDL and returns an error if
+	// the DDL can't be executed or the recreated state differs from the
+	// original.
+	RoundTrip(context.Context) error
+}
+
+// DatabaseState returns a copy of the subgraph of g rooted at the database
+// name, with the database renamed to as. Foreign keys are included if they
+// originate from within the database. The result is empty if there is no such
+// database. It allows states of databases with different names to be compared
+// with [Mismatched] or [DiffStates].
+func DatabaseState(g *dag.Graph, name, as string) *dag.Graph {
+	out := dag.New()
+
+	db, err := dag.Nodes[*Database](g, func(d *Database) bool { return d.Name == name }).TryOne()
+	if err != nil {
+		return out
+	}
+
+	nodes := append([]dag.INode{db}, dag.Descendants[dag.INode](db)...)
+	for _, fk := range dag.Nodes[*ForeignKeyConstraint](g) {
+		if fk.From().Table().Schema().Database() == db {
+			nodes = append(nodes, fk)
+		}
+	}
+
+	clones := make(map[dag.INode]dag.INode, len(nodes))
+	for _, n := range nodes {
+		clones[n] = out.AddNode(g.ID(n), dag.Clone(n))
+	}
+	clones[db].(*Database).Name = as
+
+	// Outgoing edges are copied in order as ForeignKeyConstraint relies on
+	// it.
+	for _, n := range nodes {
+		for _, to := range dag.Outgoing[dag.INode](n) {
+			if clone, ok := clones[to]; ok {
+				out.AddEdge(clones[n], clone)
+			}
+		}
+	}
+
+	return out
+}
+
+// RoundTrip implements [RoundTripper]. The output of SHOW CREATE ALL SCHEMAS,
+// TYPES and TABLES for each database is executed within a scratch database,
+// whose state is then compared to the original's. Scratch databases are
+// dropped afterwards.
+func (o *sut) RoundTrip(ctx context.Context) error {
+	original, err := o.State(ctx)
+	if err != nil {
+		return err
+	}
+
+	databases := dag.Nodes[*Database](original)
+	taken := make([]string, len(databases))
+	for i, db := range databases {
+		taken[i] = db.Name
+	}
+
+	var failures []string
+	for _, db := range databases {
+		failure, err := o.roundTrip(ctx, original, db.Name, RandomName(false, taken...))
+		if err != nil {
+			return errors.Wrapf(err, "round tripping %s", db.Name)
+		}
+		if failure != "" {
+			failures = append(failures, fmt.Sprintf("%s: %s", db.Name, failure))
+		}
+	}
+
+	if len(failures) > 0 {
+		return errors.Newf("SHOW CREATE round trip failed:\n\t%s", strings.Join(failures, "\n\t"))
+	}
+	return nil
+}
+
+// roundTrip recreates the database name as scratch. A non-empty failure
+// describes how the round trip failed while err indicates that it couldn't be
+// attempted.
+func (o *sut) roundTrip(ctx context.Context, original *dag.Graph, name, scratch string) (failure string, err error) {
+	// SHOW CREATE ALL is scoped to the current database, so a dedicated
+	// connection is used.
+	conn, err := o.conn.Connx(ctx)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	defer func() {
+		// Restore the connection's database before returning it to the pool,
+		// which may fail if the connection is broken.
+		_, _ = conn.ExecContext(ctx, `SET database = system`)
+		_ = conn.Close()
+	}()
+
+	if _, err := conn.ExecContext(ctx, `SET database = `+QuoteIdentifier(name)); err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	var stmts []string
+	for _, show := range []string{"SCHEMAS", "TYPES", "TABLES"} {
+		var out []string
+		if err := conn.SelectContext(ctx, &out, `SELECT create_statement FROM [SHOW CREATE ALL `+show+`]`); err != nil {
+			return "", errors.WithStack(err)
+		}
+		stmts = append(stmts, out...)
+	}
+
+	if _, err := conn.ExecContext(ctx, `CREATE DATABASE `+QuoteIdentifier(scratch)); err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	defer func() {
+		if _, dropErr := o.conn.ExecContext(ctx, `DROP DATABASE IF EXISTS `+QuoteIdentifier(scratch)+` CASCADE`); dropErr != nil && err == nil {
+			err = errors.Wrapf(dropErr, "dropping %s", scratch)
+		}
+		if settleErr := o.Settle(ctx); settleErr != nil && err == nil {
+			err = settleErr
+		}
+	}()
+
+	if _, err := conn.ExecContext(ctx, `SET database = `+QuoteIdentifier(scratch)); err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	for _, stmt := range stmts {
+		if isCreatePublicSchema(stmt) {
+			continue
+		}
+		o.log.Printf("Round Trip: %q", stmt)
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return fmt.Sprintf("executing %q: %v", stmt, err), nil
+		}
+	}
+
+	if err := o.Settle(ctx); err != nil {
+		return "", err
+	}
+
+	recreated, err := o.State(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	disagreements := describeMismatched(Mismatched(DatabaseState(original, name, name), DatabaseState(recreated, scratch, name)))
+	return strings.Join(disagreements, ", "), nil
+}
+
+// isCreatePublicSchema reports whether stmt creates the public schema, which
+// SHOW CREATE ALL SCHEMAS includes but every database already has.
+func isCreatePublicSchema(stmt string) bool {
+	tokens, err := tokenize(stmt)
+	if err != nil || len(tokens) < 3 {
+		return false
+	}
+	return tokens[0].is("CREATE") && tokens[1].is("SCHEMA") && tokens[2].kind == tokenWord && tokens[2].text == "public"
+}
@@ -0,0 +1,55 @@
+package pkg_test
+
+import (
+	"testing"
+
+	"github.com/chrisseto/scwl/pkg"
+	"github.com/chrisseto/scwl/pkg/dag"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDatabaseState(t *testing.T) {
+	g := dag.New()
+	for _, name := range []string{"defaultdb", "copy"} {
+		db := g.AddNode(name, &pkg.Database{Name: name})
+		schema := g.AddNode(name+".public", &pkg.Schema{Name: "public"})
+		users := g.AddNode(name+".users", &pkg.Table{Name: "users"})
+		id := g.AddNode(name+".users.id", &pkg.Column{Name: "id"})
+		posts := g.AddNode(name+".posts", &pkg.Table{Name: "posts"})
+		authorID := g.AddNode(name+".posts.author_id", &pkg.Column{Name: "author_id"})
+		fk := g.AddNode(name+".fk", &pkg.ForeignKeyConstraint{Name: "posts_author_id_fkey"})
+
+		g.AddEdge(db, schema)
+		g.AddEdge(schema, users)
+		g.AddEdge(schema, posts)
+		g.AddEdge(users, id)
+		g.AddEdge(posts, authorID)
+		g.AddEdge(fk, id)
+		g.AddEdge(fk, authorID)
+
+		if name == "copy" {
+			g.AddEdge(posts, g.AddNode(name+".posts.extra", &pkg.Column{Name: "extra"}))
+		}
+	}
+
+	original := pkg.DatabaseState(g, "defaultdb", "defaultdb")
+	require.Len(t, dag.Nodes[dag.INode](original), 7)
+
+	fk := pkg.ByFQN[*pkg.ForeignKeyConstraint](original, "defaultdb.public.posts.fks.posts_author_id_fkey")
+	require.Equal(t, "defaultdb.public.users.cols.id", pkg.FullyQualifiedName(fk.To()))
+	require.Equal(t, "defaultdb.public.posts.cols.author_id", pkg.FullyQualifiedName(fk.From()))
+
+	onlyOriginal, onlyCopy := pkg.Mismatched(original, pkg.DatabaseState(g, "copy", "defaultdb"))
+	fqns := func(nodes []dag.INode) []string {
+		var out []string
+		for _, n := range nodes {
+			out = append(out, pkg.FullyQualifiedName(n))
+		}
+		return out
+	}
+	// Adding a column also changes the outgoing edges of its table.
+	require.Equal(t, []string{"defaultdb.public.posts"}, fqns(onlyOriginal))
+	require.ElementsMatch(t, []string{"defaultdb.public.posts", "defaultdb.public.posts.cols.extra"}, fqns(onlyCopy))
+
+	require.Empty(t, dag.Nodes[dag.INode](pkg.DatabaseState(g, "missing", "missing")))
+}
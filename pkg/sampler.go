@@ -0,0 +1,174 @@
+package pkg
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chrisseto/scwl/pkg/dag"
+	"github.com/cockroachdb/errors"
+)
+
+// Sample is an observation of the SUT made within a single transaction.
+type Sample struct {
+	State *dag.Graph
+	// Columns are the columns returned by SELECT * from the table affected
+	// by a Command. It's nil if there is no such table or it doesn't exist.
+	Columns []string
+}
+
+// Sampler is implemented by Systems that can be observed while executing a
+// Command.
+type Sampler interface {
+	// ExecuteSampled executes a Command, as Execute does, while repeatedly
+	// sampling the System. execErr is the result of executing the Command
+	// while err indicates that the samples were invalid or couldn't be
+	// taken.
+	ExecuteSampled(context.Context, Command) (execErr, err error)
+}
+
+// AffectedTable returns the table that cmd modifies, if any. Commands that
+// create tables or that operate on schemas or databases return nil.
+func AffectedTable(cmd Command) *Table {
+	val := reflect.ValueOf(cmd)
+	for i := 0; i < val.NumField(); i++ {
+		switch n := val.Field(i).Interface().(type) {
+		case *Table:
+			return n
+		case *Column:
+			return n.Table()
+		case *Index:
+			return n.Table()
+		}
+	}
+	return nil
+}
+
+// CheckSamples returns an error if any of samples, taken while a Command was
+// executing, is neither the before nor the after sample in its entirety.
+// Elements that aren't public, such as write-only indexes or delete-only
+// columns, aren't loaded as part of State or returned by SELECT *, so they're
+// permitted as intermediate states.
+func CheckSamples(before, after Sample, samples []Sample) error {
+	for i, sample := range samples {
+		beforeDiff := diffSample(before, sample)
+		if beforeDiff == "" {
+			continue
+		}
+
+		afterDiff := diffSample(after, sample)
+		if afterDiff == "" {
+			continue
+		}
+
+		return errors.Newf("sample %d is neither the before nor after sample\nbefore: %s\nafter: %s", i, beforeDiff, afterDiff)
+	}
+
+	return nil
+}
+
+// diffSample describes how sample differs from expected, if at all.
+func diffSample(expected, sample Sample) string {
+	var diffs []string
+	if !reflect.DeepEqual(expected.Columns, sample.Columns) {
+		diffs = append(diffs, fmt.Sprintf("columns %q, expected %q", sample.Columns, expected.Columns))
+	}
+	if diff := DiffStates(expected.State, sample.State); diff != "" {
+		diffs = append(diffs, diff)
+	}
+	return strings.Join(diffs, "\n")
+}
+
+// sampleInterval is the delay between samples taken by ExecuteSampled.
+const sampleInterval = 10 * time.Millisecond
+
+// ExecuteSampled implements [Sampler]. Samples are taken from a separate
+// connection. The before sample is taken prior to executing cmd and the
+// after sample once the SUT has settled.
+func (o *sut) ExecuteSampled(ctx context.Context, cmd Command) (execErr, err error) {
+	var tableID int64
+	if table := AffectedTable(cmd); table != nil {
+		const tableIDQuery = `SELECT table_id
+		FROM crdb_internal.tables
+		WHERE database_name = $1 AND schema_name = $2 AND name = $3 AND drop_time IS NULL
+		`
+
+		schema := table.Schema()
+		if err := o.conn.GetContext(ctx, &tableID, tableIDQuery, schema.Database().Name, schema.Name, table.Name); err != nil {
+			return nil, errors.Wrapf(err, "resolving %s", FullyQualifiedName(table))
+		}
+	}
+
+	before, err := o.sample(ctx, tableID)
+	if err != nil {
+		return nil, errors.Wrap(err, "sampling before state")
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- o.Execute(ctx, cmd) }()
+
+	var samples []Sample
+	for running := true; running; {
+		select {
+		case execErr = <-done:
+			running = false
+			continue
+		case <-time.After(sampleInterval):
+		}
+
+		// Samples may fail due to contention with the Command, which isn't
+		// of interest.
+		if sample, err := o.sample(ctx, tableID); err == nil {
+			samples = append(samples, sample)
+		}
+	}
+
+	if err := o.Settle(ctx); err != nil {
+		return execErr, err
+	}
+
+	after, err := o.sample(ctx, tableID)
+	if err != nil {
+		return execErr, errors.Wrap(err, "sampling after state")
+	}
+
+	o.log.Printf("Took %d samples", len(samples))
+	return execErr, CheckSamples(before, after, samples)
+}
+
+// sample observes the SUT within a single transaction so that the catalog is
+// read at a consistent timestamp. If tableID is non-zero, the columns of the
+// table are included.
+func (o *sut) sample(ctx context.Context, tableID int64) (Sample, error) {
+	tx, err := o.conn.BeginTxx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return Sample{}, errors.WithStack(err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	state, err := o.state(ctx, tx)
+	if err != nil {
+		return Sample{}, err
+	}
+
+	sample := Sample{State: state}
+	if tableID == 0 {
+		return sample, nil
+	}
+
+	// A numeric table reference resolves the table regardless of renames.
+	// An error indicates that the table doesn't exist, which is left as nil
+	// Columns. It aborts the transaction so must be the final query.
+	rows, err := tx.QueryContext(ctx, `SELECT * FROM [`+strconv.FormatInt(tableID, 10)+` AS t] LIMIT 0`)
+	if err != nil {
+		return sample, nil
+	}
+	defer rows.Close()
+
+	sample.Columns, err = rows.Columns()
+	return sample, errors.WithStack(err)
+}
@@ -0,0 +1,36 @@
+package pkg_test
+
+import (
+	"testing"
+
+	"github.com/chrisseto/scwl/pkg"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckSamples(t *testing.T) {
+	before := pkg.Sample{State: publicState("users"), Columns: []string{"id"}}
+	after := pkg.Sample{State: publicState("people"), Columns: []string{"id", "email"}}
+
+	require.NoError(t, pkg.CheckSamples(before, after, []pkg.Sample{before, after, before, after}))
+
+	err := pkg.CheckSamples(before, after, []pkg.Sample{before, {State: publicState("users", "people"), Columns: []string{"id"}}})
+	require.ErrorContains(t, err, "sample 1 is neither the before nor after sample")
+
+	err = pkg.CheckSamples(before, after, []pkg.Sample{after, {State: publicState("people"), Columns: []string{"email"}}})
+	require.ErrorContains(t, err, `columns ["email"], expected ["id" "email"]`)
+
+	// Columns and state must be from the same sample.
+	err = pkg.CheckSamples(before, after, []pkg.Sample{{State: after.State, Columns: before.Columns}})
+	require.EqualError(t, err, `sample 0 is neither the before nor after sample
+before: `+pkg.DiffStates(before.State, after.State)+`
+after: columns ["id"], expected ["id" "email"]`)
+
+	g := before.State
+	users := pkg.ByFQN[*pkg.Table](g, "defaultdb.public.users")
+	id := g.AddNode("id", &pkg.Column{Name: "id"}).(*pkg.Column)
+	g.AddEdge(users, id)
+
+	require.Equal(t, users, pkg.AffectedTable(pkg.RenameTable{Table: users, Name: "people"}))
+	require.Equal(t, users, pkg.AffectedTable(pkg.DropColumn{Column: id}))
+	require.Nil(t, pkg.AffectedTable(pkg.CreateTable{Schema: users.Schema(), Name: "posts"}))
+}
@@ -0,0 +1,73 @@
+package pkg
+
+import (
+	"context"
+
+	"github.com/cockroachdb/errors"
+)
+
+// SchemaChanger selects which of CockroachDB's schema changers the SUT
+// executes a Command with.
+type SchemaChanger string
+
+const (
+	// SchemaChangerDefault leaves use_declarative_schema_changer untouched.
+	SchemaChangerDefault SchemaChanger = ""
+	// SchemaChangerLegacy executes Commands with the legacy schema changer.
+	SchemaChangerLegacy SchemaChanger = "legacy"
+	// SchemaChangerDeclarative executes Commands with the declarative schema
+	// changer, falling back to the legacy schema changer for statements it
+	// doesn't support.
+	SchemaChangerDeclarative SchemaChanger = "declarative"
+	// SchemaChangerRandom picks between legacy and declarative per Command.
+	SchemaChangerRandom SchemaChanger = "random"
+)
+
+// Validate returns an error if s isn't a known SchemaChanger.
+func (s SchemaChanger) Validate() error {
+	switch s {
+	case SchemaChangerDefault, SchemaChangerLegacy, SchemaChangerDeclarative, SchemaChangerRandom:
+		return nil
+	default:
+		return errors.Newf("unknown schema changer %q", s)
+	}
+}
+
+// Resolve returns the SchemaChanger to execute a single Command with.
+// SchemaChangerRandom resolves to either legacy or declarative.
+func (s SchemaChanger) Resolve() SchemaChanger {
+	if s != SchemaChangerRandom {
+		return s
+	}
+	if FlipCoin() {
+		return SchemaChangerLegacy
+	}
+	return SchemaChangerDeclarative
+}
+
+// setting returns the value of use_declarative_schema_changer for s.
+func (s SchemaChanger) setting() string {
+	switch s {
+	case SchemaChangerLegacy:
+		return "off"
+	case SchemaChangerDeclarative:
+		return "on"
+	default:
+		panic(errors.Newf("schema changer %q has no setting", s))
+	}
+}
+
+type schemaChangerKey struct{}
+
+// WithSchemaChanger returns a context that instructs the SUT to execute
+// Commands with s.
+func WithSchemaChanger(ctx context.Context, s SchemaChanger) context.Context {
+	return context.WithValue(ctx, schemaChangerKey{}, s)
+}
+
+// SchemaChangerFrom returns the SchemaChanger set by WithSchemaChanger or
+// SchemaChangerDefault if there is none.
+func SchemaChangerFrom(ctx context.Context) SchemaChanger {
+	s, _ := ctx.Value(schemaChangerKey{}).(SchemaChanger)
+	return s
+}
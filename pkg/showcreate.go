@@ -0,0 +1,454 @@
+package pkg
+
+import (
+	"strings"
+
+	"github.com/chrisseto/scwl/pkg/dag"
+	"github.com/cockroachdb/errors"
+)
+
+// tokenKind classifies the tokens of a SHOW CREATE statement.
+type tokenKind int
+
+const (
+	tokenWord tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenPunct
+)
+
+type sqlToken struct {
+	kind tokenKind
+	text string
+}
+
+// is reports whether t is the unquoted keyword or punctuation s. Quoted
+// identifiers never match, so `"index"` remains a name.
+func (t sqlToken) is(s string) bool {
+	return (t.kind == tokenWord || t.kind == tokenPunct) && strings.EqualFold(t.text, s)
+}
+
+// name reports whether t may be used as a name.
+func (t sqlToken) name() bool {
+	return t.kind == tokenWord || t.kind == tokenIdent
+}
+
+const punctuation = "(),.;"
+
+// tokenize splits stmt into words, double quoted identifiers, string
+// literals and punctuation. Quotes are removed from identifiers and literals.
+func tokenize(stmt string) ([]sqlToken, error) {
+	var tokens []sqlToken
+	for i := 0; i < len(stmt); {
+		c := stmt[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+
+		case c == '"' || c == '\'':
+			kind := tokenIdent
+			if c == '\'' {
+				kind = tokenString
+			}
+
+			var b strings.Builder
+			j := i + 1
+			for ; ; j++ {
+				if j >= len(stmt) {
+					return nil, errors.Newf("unterminated %c at offset %d", c, i)
+				}
+				if stmt[j] == c {
+					// A doubled quote is an escaped quote.
+					if j+1 < len(stmt) && stmt[j+1] == c {
+						j++
+					} else {
+						break
+					}
+				}
+				b.WriteByte(stmt[j])
+			}
+			tokens = append(tokens, sqlToken{kind: kind, text: b.String()})
+			i = j + 1
+
+		case strings.IndexByte(punctuation, c) >= 0:
+			tokens = append(tokens, sqlToken{kind: tokenPunct, text: string(c)})
+			i++
+
+		default:
+			j := i
+			for j < len(stmt) && !strings.ContainsRune(" \t\n\r\"'"+punctuation, rune(stmt[j])) {
+				j++
+			}
+			tokens = append(tokens, sqlToken{kind: tokenWord, text: stmt[i:j]})
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+// stmtParser consumes the tokens of a single statement.
+type stmtParser struct {
+	tokens []sqlToken
+	pos    int
+}
+
+func (p *stmtParser) peek(offset int) sqlToken {
+	if p.pos+offset >= len(p.tokens) {
+		return sqlToken{kind: tokenPunct, text: ";"}
+	}
+	return p.tokens[p.pos+offset]
+}
+
+func (p *stmtParser) next() sqlToken {
+	t := p.peek(0)
+	p.pos++
+	return t
+}
+
+func (p *stmtParser) expect(keywords ...string) error {
+	for _, kw := range keywords {
+		if t := p.next(); !t.is(kw) {
+			return errors.Newf("expected %s, found %q", kw, t.text)
+		}
+	}
+	return nil
+}
+
+// name parses a possibly qualified name into its parts.
+func (p *stmtParser) name() ([]string, error) {
+	var parts []string
+	for {
+		t := p.next()
+		if !t.name() {
+			return nil, errors.Newf("expected a name, found %q", t.text)
+		}
+		parts = append(parts, t.text)
+
+		if !p.peek(0).is(".") {
+			return parts, nil
+		}
+		p.pos++
+	}
+}
+
+// list parses a parenthesized list into its top level, comma separated
+// elements.
+func (p *stmtParser) list() ([][]sqlToken, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+
+	var elems [][]sqlToken
+	var elem []sqlToken
+	for depth := 0; ; {
+		if p.pos >= len(p.tokens) {
+			return nil, errors.New("unterminated (")
+		}
+
+		t := p.next()
+		switch {
+		case t.is("("):
+			depth++
+		case t.is(")") && depth == 0:
+			if len(elem) > 0 {
+				elems = append(elems, elem)
+			}
+			return elems, nil
+		case t.is(")"):
+			depth--
+		case t.is(",") && depth == 0:
+			elems = append(elems, elem)
+			elem = nil
+			continue
+		}
+		elem = append(elem, t)
+	}
+}
+
+// foreignKey is a FOREIGN KEY constraint that's resolved once all tables have
+// been loaded, as it may reference a table that's created later.
+type foreignKey struct {
+	name       string
+	from       []string
+	fromColumn string
+	to         []string
+	toColumn   string
+}
+
+// foreignKey parses `FOREIGN KEY (a) REFERENCES t (b)`. Only the first column
+// of multi-column FKs is kept, which matches State.
+func (p *stmtParser) foreignKey(name string, from []string) (foreignKey, error) {
+	fk := foreignKey{name: name, from: from}
+
+	if err := p.expect("FOREIGN", "KEY"); err != nil {
+		return fk, err
+	}
+
+	cols, err := p.list()
+	if err != nil {
+		return fk, err
+	}
+
+	if err := p.expect("REFERENCES"); err != nil {
+		return fk, err
+	}
+
+	if fk.to, err = p.name(); err != nil {
+		return fk, err
+	}
+
+	refs, err := p.list()
+	if err != nil {
+		return fk, err
+	}
+
+	if len(cols) == 0 || len(refs) == 0 {
+		return fk, errors.Newf("foreign key %s has no columns", name)
+	}
+
+	fk.fromColumn = cols[0][0].text
+	fk.toColumn = refs[0][0].text
+	return fk, nil
+}
+
+// LoadCreateStatements adds the tables described by stmts, the output of SHOW
+// CREATE ALL TABLES run within db, to g. The schemas of db must already be in
+// g. Hidden columns, primary keys and constraints other than foreign keys are
+// ignored, as they are by State. Statements that don't create tables or add
+// foreign keys, such as CREATE VIEW, are skipped.
+func LoadCreateStatements(g *dag.Graph, db *Database, stmts []string) error {
+	l := &createLoader{g: g, db: db}
+
+	for _, stmt := range stmts {
+		tokens, err := tokenize(stmt)
+		if err != nil {
+			return errors.Wrapf(err, "tokenizing %q", stmt)
+		}
+
+		p := &stmtParser{tokens: tokens}
+		switch {
+		case p.peek(0).is("CREATE") && p.peek(1).is("TABLE"):
+			p.pos += 2
+			err = l.createTable(p)
+		case p.peek(0).is("ALTER") && p.peek(1).is("TABLE"):
+			p.pos += 2
+			err = l.alterTable(p)
+		}
+
+		if err != nil {
+			return errors.Wrapf(err, "parsing %q", stmt)
+		}
+	}
+
+	for _, fk := range l.fks {
+		if err := l.addForeignKey(fk); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type createLoader struct {
+	g   *dag.Graph
+	db  *Database
+	fks []foreignKey
+}
+
+// tableID returns the node ID of the table name, which is qualified by its
+// schema and, optionally, db, along with its schema.
+func (l *createLoader) tableID(name []string) (string, *Schema, error) {
+	switch len(name) {
+	case 1:
+		name = []string{"public", name[0]}
+	case 2:
+	case 3:
+		if name[0] != l.db.Name {
+			return "", nil, errors.Newf("%s references another database", strings.Join(name, "."))
+		}
+		name = name[1:]
+	default:
+		return "", nil, errors.Newf("invalid table name %s", strings.Join(name, "."))
+	}
+
+	for _, schema := range l.db.Schemas() {
+		if schema.Name == name[0] {
+			return l.g.ID(schema) + "." + QuoteIdentifier(name[1]), schema, nil
+		}
+	}
+	return "", nil, errors.Newf("unknown schema %s", name[0])
+}
+
+func (l *createLoader) createTable(p *stmtParser) error {
+	if p.peek(0).is("IF") {
+		if err := p.expect("IF", "NOT", "EXISTS"); err != nil {
+			return err
+		}
+	}
+
+	name, err := p.name()
+	if err != nil {
+		return err
+	}
+
+	tableID, schema, err := l.tableID(name)
+	if err != nil {
+		return err
+	}
+
+	table := l.g.AddNode(tableID, &Table{Name: name[len(name)-1]})
+	l.g.AddEdge(schema, table)
+
+	elems, err := p.list()
+	if err != nil {
+		return err
+	}
+
+	// Indexes may only be added once all columns are known.
+	var indexes [][]sqlToken
+	for _, elem := range elems {
+		// Clauses and columns are distinguished by their shape as a column
+		// may be named after an unreserved keyword, eg: `family STRING`.
+		clause := len(elem) > 1 && elem[1].is("(") || len(elem) > 2 && elem[2].is("(")
+		switch {
+		case elem[0].is("CONSTRAINT") && len(elem) > 2 && elem[2].is("FOREIGN"):
+			fk, err := (&stmtParser{tokens: elem[2:]}).foreignKey(elem[1].text, name)
+			if err != nil {
+				return err
+			}
+			l.fks = append(l.fks, fk)
+
+		case elem[0].is("CONSTRAINT"),
+			elem[0].is("PRIMARY") && len(elem) > 1 && elem[1].is("KEY"),
+			elem[0].is("CHECK") && len(elem) > 1 && elem[1].is("("),
+			elem[0].is("FAMILY") && clause:
+			// Not part of State.
+
+		case elem[0].is("INDEX") && clause,
+			elem[0].is("UNIQUE") && len(elem) > 1 && elem[1].is("INDEX"):
+			indexes = append(indexes, elem)
+
+		case elem[0].is("INVERTED") && len(elem) > 1 && elem[1].is("INDEX"):
+			return errors.New("inverted indexes are not supported")
+
+		default:
+			if !elem[0].name() {
+				return errors.Newf("expected a column, found %q", elem[0].text)
+			}
+			if hidden(elem) {
+				continue
+			}
+			column := l.g.AddNode(tableID+".cols."+QuoteIdentifier(elem[0].text), &Column{Name: elem[0].text})
+			l.g.AddEdge(table, column)
+		}
+	}
+
+	for _, elem := range indexes {
+		if err := l.addIndex(tableID, table, elem); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// hidden reports whether the column definition elem is NOT VISIBLE.
+func hidden(elem []sqlToken) bool {
+	for i := 1; i < len(elem); i++ {
+		if elem[i-1].is("NOT") && elem[i].is("VISIBLE") {
+			return true
+		}
+	}
+	return false
+}
+
+// addIndex adds the index definition elem, `[UNIQUE] INDEX name (cols...)`,
+// to table. STORING columns aren't key columns so aren't included.
+func (l *createLoader) addIndex(tableID string, table dag.INode, elem []sqlToken) error {
+	p := &stmtParser{tokens: elem}
+
+	unique := p.peek(0).is("UNIQUE")
+	if unique {
+		p.pos++
+	}
+	if err := p.expect("INDEX"); err != nil {
+		return err
+	}
+
+	name := p.next()
+	if !name.name() {
+		return errors.Newf("expected an index name, found %q", name.text)
+	}
+
+	cols, err := p.list()
+	if err != nil {
+		return err
+	}
+
+	index := l.g.AddNode(tableID+".idxs."+QuoteIdentifier(name.text), &Index{Name: name.text, Unique: unique})
+	l.g.AddEdge(table, index)
+
+	for _, col := range cols {
+		column := l.g.ByID(tableID + ".cols." + QuoteIdentifier(col[0].text))
+		if column == nil {
+			return errors.Newf("index %s references unknown column %s", name.text, col[0].text)
+		}
+		l.g.AddEdge(index, column)
+	}
+
+	return nil
+}
+
+// alterTable parses `ALTER TABLE t ADD CONSTRAINT c FOREIGN KEY ...`, which is
+// how SHOW CREATE ALL TABLES emits foreign keys. Other alterations, such as
+// VALIDATE CONSTRAINT, are skipped.
+func (l *createLoader) alterTable(p *stmtParser) error {
+	name, err := p.name()
+	if err != nil {
+		return err
+	}
+
+	if !p.peek(0).is("ADD") || !p.peek(1).is("CONSTRAINT") || !p.peek(3).is("FOREIGN") {
+		return nil
+	}
+
+	fkName := p.peek(2).text
+	p.pos += 3
+
+	fk, err := p.foreignKey(fkName, name)
+	if err != nil {
+		return err
+	}
+	l.fks = append(l.fks, fk)
+	return nil
+}
+
+func (l *createLoader) addForeignKey(fk foreignKey) error {
+	column := func(table []string, name string) (dag.INode, error) {
+		tableID, _, err := l.tableID(table)
+		if err != nil {
+			return nil, err
+		}
+		n := l.g.ByID(tableID + ".cols." + QuoteIdentifier(name))
+		if n == nil {
+			return nil, errors.Newf("foreign key %s references unknown column %s.%s", fk.name, strings.Join(table, "."), name)
+		}
+		return n, nil
+	}
+
+	to, err := column(fk.to, fk.toColumn)
+	if err != nil {
+		return err
+	}
+
+	from, err := column(fk.from, fk.fromColumn)
+	if err != nil {
+		return err
+	}
+
+	fromTableID, _, _ := l.tableID(fk.from)
+	n := l.g.AddNode(fromTableID+".fks."+QuoteIdentifier(fk.name), &ForeignKeyConstraint{Name: fk.name})
+	l.g.AddEdge(n, to)
+	l.g.AddEdge(n, from)
+	return nil
+}
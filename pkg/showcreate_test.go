@@ -0,0 +1,78 @@
+package pkg_test
+
+import (
+	"testing"
+
+	"github.com/chrisseto/scwl/pkg"
+	"github.com/chrisseto/scwl/pkg/dag"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadCreateStatements(t *testing.T) {
+	g := dag.New()
+	db := g.AddNode("defaultdb", &pkg.Database{Name: "defaultdb"}).(*pkg.Database)
+	g.AddEdge(db, g.AddNode("defaultdb.public", &pkg.Schema{Name: "public"}))
+	g.AddEdge(db, g.AddNode("defaultdb.my schema", &pkg.Schema{Name: "my schema"}))
+
+	stmts := []string{
+		`CREATE TABLE "my schema".posts (
+	author_id INT8 NULL,
+	family STRING NULL,
+	rowid INT8 NOT VISIBLE NOT NULL DEFAULT unique_rowid(),
+	CONSTRAINT posts_pkey PRIMARY KEY (rowid ASC),
+	FAMILY "primary" (author_id, family, rowid)
+)`,
+		`CREATE TABLE public.users (
+	id INT8 NOT NULL,
+	"Email" STRING NOT NULL DEFAULT 'it''s, (not) a column',
+	"index" STRING NULL,
+	CONSTRAINT users_pkey PRIMARY KEY (id ASC),
+	UNIQUE INDEX "users_Email_key" ("Email" ASC),
+	INDEX users_idx ("index" ASC, id DESC) STORING (family),
+	CONSTRAINT users_id_check CHECK (id > 0)
+)`,
+		`CREATE VIEW public.emails (email) AS SELECT "Email" FROM defaultdb.public.users`,
+		`ALTER TABLE "my schema".posts ADD CONSTRAINT posts_author_id_fkey FOREIGN KEY (author_id) REFERENCES public.users(id);`,
+		`ALTER TABLE "my schema".posts VALIDATE CONSTRAINT posts_author_id_fkey;`,
+	}
+
+	require.NoError(t, pkg.LoadCreateStatements(g, db, stmts))
+
+	posts := pkg.ByFQN[*pkg.Table](g, "defaultdb.my schema.posts")
+	require.Equal(t, []string{"author_id", "family"}, names(posts.Columns()))
+	require.Empty(t, posts.Indexes())
+
+	users := pkg.ByFQN[*pkg.Table](g, "defaultdb.public.users")
+	require.Equal(t, []string{"id", "Email", "index"}, names(users.Columns()))
+	require.Equal(t, []string{"users_Email_key", "users_idx"}, names(users.Indexes()))
+
+	unique := pkg.ByFQN[*pkg.Index](g, "defaultdb.public.users.idxs.users_Email_key")
+	require.True(t, unique.Unique)
+	require.Equal(t, []string{"Email"}, names(unique.Columns()))
+
+	index := pkg.ByFQN[*pkg.Index](g, "defaultdb.public.users.idxs.users_idx")
+	require.False(t, index.Unique)
+	require.Equal(t, []string{"index", "id"}, names(index.Columns()))
+
+	fk := pkg.ByFQN[*pkg.ForeignKeyConstraint](g, "defaultdb.my schema.posts.fks.posts_author_id_fkey")
+	require.Equal(t, "defaultdb.public.users.cols.id", pkg.FullyQualifiedName(fk.To()))
+	require.Equal(t, "defaultdb.my schema.posts.cols.author_id", pkg.FullyQualifiedName(fk.From()))
+
+	err := pkg.LoadCreateStatements(g, db, []string{`CREATE TABLE other.t (a INT8)`})
+	require.ErrorContains(t, err, "unknown schema other")
+}
+
+func names[T interface {
+	*pkg.Column | *pkg.Index
+}](nodes []T) []string {
+	var out []string
+	for _, n := range nodes {
+		switch n := any(n).(type) {
+		case *pkg.Column:
+			out = append(out, n.Name)
+		case *pkg.Index:
+			out = append(out, n.Name)
+		}
+	}
+	return out
+}
@@ -8,6 +8,15 @@ import (
 	"github.com/jmoiron/sqlx"
 )
 
+func init() {
+	dag.Register[Database]()
+	dag.Register[Schema]()
+	dag.Register[Table]()
+	dag.Register[Column]()
+	dag.Register[Index]()
+	dag.Register[ForeignKeyConstraint]()
+}
+
 type Database struct {
 	dag.Node
 	Name string `db:"name"`
@@ -70,7 +79,7 @@ type Queries struct {
 	// ColumnsToForeignKeyConstraints string
 }
 
-func loadState(ctx context.Context, conn *sqlx.DB, queries Queries) (*dag.Graph, error) {
+func loadState(ctx context.Context, conn sqlx.QueryerContext, queries Queries) (*dag.Graph, error) {
 	var databases []struct {
 		ID string `db:"id"`
 		Database
@@ -139,7 +148,7 @@ func loadState(ctx context.Context, conn *sqlx.DB, queries Queries) (*dag.Graph,
 		return nil, errors.WithStack(err)
 	}
 
-	g := dag.New(clone)
+	g := dag.New()
 
 	for i := range databases {
 		db := &databases[i]
@@ -176,8 +185,11 @@ func loadState(ctx context.Context, conn *sqlx.DB, queries Queries) (*dag.Graph,
 
 	for i := range foreignKeyConstraints {
 		fk := &foreignKeyConstraints[i]
-		id := RandomString() // A bit weird but FKs are currently seen as composites rather than their own entity. 
-		g.AddNode(id, &fk.ForeignKeyConstraint)
+		// FKs don't have IDs of their own, so they're identified by their
+		// name within the referencing table, which keeps encoded states
+		// deterministic.
+		from := dag.ByID[*Column](g, fk.FromID)
+		g.AddNode(FullyQualifiedName(from.Table())+".fks."+escapeName(fk.Name), &fk.ForeignKeyConstraint)
 
 		g.AddEdge(&fk.ForeignKeyConstraint, g.ByID(fk.ToID))
 		g.AddEdge(&fk.ForeignKeyConstraint, g.ByID(fk.FromID))
@@ -185,31 +197,3 @@ func loadState(ctx context.Context, conn *sqlx.DB, queries Queries) (*dag.Graph,
 
 	return g, nil
 }
-
-func clone(in dag.INode) dag.INode {
-	// Go is weird. We can't clone a struct that implements an interface
-	// without doing some reflection magic. Instead, opted for a repetitive
-	// type switch. Could probably code gen it.
-	switch n := in.(type) {
-	case *Schema:
-		o := *n
-		return &o
-	case *Database:
-		o := *n
-		return &o
-	case *Table:
-		o := *n
-		return &o
-	case *Column:
-		o := *n
-		return &o
-	case *Index:
-		o := *n
-		return &o
-	case *ForeignKeyConstraint:
-		o := *n
-		return &o
-	default:
-		panic(errors.Newf("unhandled type %T", in))
-	}
-}
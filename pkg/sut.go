@@ -2,9 +2,14 @@ package pkg
 
 import (
 	"context"
+	"database/sql/driver"
+	"fmt"
 	"log"
+	"strings"
+	"time"
 
 	"github.com/chrisseto/scwl/pkg/dag"
+	"github.com/cockroachdb/errors"
 	"github.com/jmoiron/sqlx"
 )
 
@@ -17,14 +22,49 @@ func NewSUT(conn *sqlx.DB, log *log.Logger) *sut {
 	return &sut{conn: conn, log: log}
 }
 
-func (o *sut) Execute(ctx context.Context, cmd Command) error {
+func (o *sut) Execute(ctx context.Context, cmd Command) (err error) {
 	stmt := AsDDL(cmd)
-	o.log.Printf("Running: %q", stmt)
-	_, err := o.conn.ExecContext(ctx, stmt)
+
+	schemaChanger := SchemaChangerFrom(ctx).Resolve()
+	if schemaChanger == SchemaChangerDefault {
+		o.log.Printf("Running: %q", stmt)
+		_, err := o.conn.ExecContext(ctx, stmt)
+		return err
+	}
+
+	// Session settings are per connection, so the setting and statement must
+	// share a dedicated connection rather than the pool.
+	conn, err := o.conn.Connx(ctx)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	// The setting must not leak into the pool with conn. If it can't be
+	// reset, conn is discarded rather than returned to the pool.
+	defer func() {
+		if _, resetErr := conn.ExecContext(ctx, "RESET use_declarative_schema_changer"); resetErr != nil {
+			_ = conn.Raw(func(any) error { return driver.ErrBadConn })
+			if err == nil {
+				err = errors.Wrap(resetErr, "resetting use_declarative_schema_changer")
+			}
+		}
+		conn.Close()
+	}()
+
+	if _, err := conn.ExecContext(ctx, "SET use_declarative_schema_changer = "+QuoteLiteral(schemaChanger.setting())); err != nil {
+		return errors.WithStack(err)
+	}
+
+	o.log.Printf("Running (%s schema changer): %q", schemaChanger, stmt)
+	_, err = conn.ExecContext(ctx, stmt)
 	return err
 }
 
 func (o *sut) State(ctx context.Context) (*dag.Graph, error) {
+	return o.state(ctx, o.conn)
+}
+
+// state loads the state of the SUT using conn, which may be a transaction.
+func (o *sut) state(ctx context.Context, conn sqlx.QueryerContext) (*dag.Graph, error) {
 	const databasesQuery = `SELECT id, name FROM crdb_internal.databases WHERE name NOT IN ('system') ORDER BY name DESC`
 
 	const schemasQuery = `SELECT id, "parentID" as database_id, name FROM system.namespace WHERE "parentSchemaID" = 0 AND "parentID" > 1 ORDER BY name DESC`
@@ -85,7 +125,7 @@ func (o *sut) State(ctx context.Context) (*dag.Graph, error) {
 	) ORDER BY name DESC
 	`
 
-	return loadState(ctx, o.conn, Queries{
+	return loadState(ctx, conn, Queries{
 		Databases:             databasesQuery,
 		Schemas:               schemasQuery,
 		Tables:                tablesQuery,
@@ -95,3 +135,122 @@ func (o *sut) State(ctx context.Context) (*dag.Graph, error) {
 		ForeignKeyConstraints: fkQuery,
 	})
 }
+
+// DefaultSettleTimeout bounds how long Settle waits for schema change jobs if
+// its context has no deadline.
+const DefaultSettleTimeout = 5 * time.Minute
+
+// Job is a schema change job as reported by crdb_internal.jobs.
+type Job struct {
+	ID            int64  `db:"job_id"`
+	Type          string `db:"job_type"`
+	Status        string `db:"status"`
+	RunningStatus string `db:"running_status"`
+	Error         string `db:"error"`
+	Description   string `db:"description"`
+}
+
+func (j Job) String() string {
+	s := fmt.Sprintf("job %d (%s) %s", j.ID, j.Type, j.Status)
+	if j.RunningStatus != "" {
+		s += fmt.Sprintf(" [%s]", j.RunningStatus)
+	}
+	if j.Error != "" {
+		s += fmt.Sprintf(" error: %s", j.Error)
+	}
+	return s + ": " + j.Description
+}
+
+// HungJobsError is returned by Settle if schema change jobs are paused, so
+// won't settle by themselves, or fail to reach a terminal state in time.
+type HungJobsError struct {
+	// Timeout is how long Settle waited for Jobs. It's zero if Jobs are
+	// paused.
+	Timeout time.Duration
+	Jobs    []Job
+}
+
+func (e *HungJobsError) Error() string {
+	var b strings.Builder
+	if e.Timeout == 0 {
+		fmt.Fprintf(&b, "%d schema change jobs are paused:", len(e.Jobs))
+	} else {
+		fmt.Fprintf(&b, "%d schema change jobs did not settle within %s:", len(e.Jobs), e.Timeout)
+	}
+	for _, job := range e.Jobs {
+		fmt.Fprintf(&b, "\n\t%s", job)
+	}
+	return b.String()
+}
+
+// Settle implements [Settler] by waiting for all schema change jobs to reach a
+// terminal state. As Commands are executed one at a time, any outstanding job
+// belongs to a descriptor affected by the most recent Command, or one before
+// it that left work behind. GC jobs are excluded as they wait out the GC TTL
+// of dropped descriptors, which is why State still filters out dropped
+// tables. If ctx has no deadline, DefaultSettleTimeout is used. Jobs that
+// don't settle in time, or are paused, are reported with a *HungJobsError.
+func (o *sut) Settle(ctx context.Context) error {
+	const pendingJobsQuery = `SELECT
+		job_id,
+		job_type,
+		status,
+		coalesce(running_status, '') AS running_status,
+		coalesce(error, '') AS error,
+		description
+	FROM crdb_internal.jobs
+	WHERE job_type IN ('SCHEMA CHANGE', 'NEW SCHEMA CHANGE')
+	AND status NOT IN ('succeeded', 'failed', 'canceled')
+	ORDER BY created
+	`
+
+	timeout := DefaultSettleTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+
+	var pending []Job
+	for {
+		// Errors are expected while nodes are restarting, keep polling until
+		// the timeout.
+		var jobs []Job
+		err := o.conn.SelectContext(ctx, &jobs, pendingJobsQuery)
+		if err == nil {
+			if len(jobs) == 0 {
+				return nil
+			}
+			pending = jobs
+
+			// Paused jobs won't settle until they're resumed, which nothing
+			// else will do.
+			if paused := pausedJobs(jobs); len(paused) > 0 {
+				return &HungJobsError{Jobs: paused}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			if len(pending) == 0 {
+				return errors.Wrapf(err, "jobs did not settle within %s", timeout)
+			}
+			return &HungJobsError{Timeout: timeout, Jobs: pending}
+		case <-ticker.C:
+		}
+	}
+}
+
+func pausedJobs(jobs []Job) []Job {
+	var paused []Job
+	for _, job := range jobs {
+		if job.Status == "paused" {
+			paused = append(paused, job)
+		}
+	}
+	return paused
+}
@@ -0,0 +1,106 @@
+package pkg_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chrisseto/scwl/pkg"
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+// sessionConnector is a driver.Connector whose connections track the
+// use_declarative_schema_changer session setting. Every other statement
+// records the setting of the connection that executed it.
+type sessionConnector struct {
+	settings []string
+}
+
+func (c *sessionConnector) Connect(context.Context) (driver.Conn, error) {
+	return &sessionConn{connector: c}, nil
+}
+
+func (c *sessionConnector) Driver() driver.Driver {
+	panic("not implemented")
+}
+
+type sessionConn struct {
+	connector *sessionConnector
+	setting   string
+}
+
+func (c *sessionConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	const set = "SET use_declarative_schema_changer = "
+	switch {
+	case strings.HasPrefix(query, set):
+		c.setting = strings.Trim(strings.TrimPrefix(query, set), "'")
+	case query == "RESET use_declarative_schema_changer":
+		c.setting = ""
+	default:
+		c.connector.settings = append(c.connector.settings, c.setting)
+	}
+	return driver.RowsAffected(0), nil
+}
+
+func (c *sessionConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (c *sessionConn) Close() error {
+	return nil
+}
+
+func (c *sessionConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("not implemented")
+}
+
+func TestSUTSchemaChangerReset(t *testing.T) {
+	connector := &sessionConnector{}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	// A single connection ensures that every execution shares a session.
+	db.SetMaxOpenConns(1)
+
+	sut := pkg.NewSUT(sqlx.NewDb(db, "pgx"), log.New(io.Discard, "", 0))
+	ctx := context.Background()
+
+	for _, schemaChanger := range []pkg.SchemaChanger{
+		pkg.SchemaChangerLegacy,
+		pkg.SchemaChangerDefault,
+		pkg.SchemaChangerDeclarative,
+		pkg.SchemaChangerDefault,
+	} {
+		require.NoError(t, sut.Execute(pkg.WithSchemaChanger(ctx, schemaChanger), pkg.CreateDatabase{Name: "db"}))
+	}
+
+	// Default executions see the cluster default, an unset setting.
+	require.Equal(t, []string{"off", "", "on", ""}, connector.settings)
+}
+
+func TestHungJobsError(t *testing.T) {
+	err := &pkg.HungJobsError{
+		Timeout: time.Minute,
+		Jobs: []pkg.Job{
+			{ID: 1, Type: "SCHEMA CHANGE", Status: "running", RunningStatus: "performing backfill", Description: "CREATE INDEX foo ON bar (baz)"},
+			{ID: 2, Type: "NEW SCHEMA CHANGE", Status: "reverting", Error: "node unavailable", Description: "ALTER TABLE bar ADD COLUMN qux INT8"},
+		},
+	}
+
+	require.EqualError(t, err, `2 schema change jobs did not settle within 1m0s:
+	job 1 (SCHEMA CHANGE) running [performing backfill]: CREATE INDEX foo ON bar (baz)
+	job 2 (NEW SCHEMA CHANGE) reverting error: node unavailable: ALTER TABLE bar ADD COLUMN qux INT8`)
+
+	err = &pkg.HungJobsError{
+		Jobs: []pkg.Job{{ID: 3, Type: "NEW SCHEMA CHANGE", Status: "paused", Description: "DROP TABLE bar"}},
+	}
+	require.EqualError(t, err, `1 schema change jobs are paused:
+	job 3 (NEW SCHEMA CHANGE) paused: DROP TABLE bar`)
+}
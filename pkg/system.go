@@ -4,6 +4,7 @@ import (
 	"context"
 	"math/rand"
 	"strings"
+	"unicode"
 
 	"github.com/chrisseto/scwl/pkg/dag"
 )
@@ -16,6 +17,12 @@ type System interface {
 	State(context.Context) (*dag.Graph, error)
 }
 
+// Settler is implemented by Systems that perform work asynchronously, such as
+// schema change jobs. Settle blocks until that work has completed.
+type Settler interface {
+	Settle(context.Context) error
+}
+
 func FlipCoin() bool {
 	return rand.Intn(2) == 0
 }
@@ -28,6 +35,92 @@ func RandomString(prefixes ...string) string {
 	return strings.Join(prefixes, "_")
 }
 
+// RandomName returns a random identifier that is not present in taken. If
+// adversarial is true, the identifier may be a reserved keyword, be unusually
+// long or contain mixed case, quotes, dots, unicode or whitespace.
+func RandomName(adversarial bool, taken ...string) string {
+	isTaken := func(name string) bool {
+		for _, t := range taken {
+			if t == name {
+				return true
+			}
+		}
+		return false
+	}
+
+	for {
+		name := RandomString()
+		if adversarial {
+			name = adversarialName()
+		}
+		if !isTaken(name) {
+			return name
+		}
+	}
+}
+
+// adversarialName returns a random identifier that's likely to trip up name
+// handling. Half of the time the result is used verbatim, otherwise it's
+// joined with a RandomString to reduce the likelihood of collisions.
+func adversarialName() string {
+	var name string
+	switch rand.Intn(7) {
+	case 0:
+		name = keywords[rand.Intn(len(keywords))]
+	case 1:
+		// Mixed case.
+		b := []byte(RandomString())
+		for i := range b {
+			if FlipCoin() {
+				b[i] = byte(unicode.ToUpper(rune(b[i])))
+			}
+		}
+		name = string(b)
+	case 2:
+		name = []string{`"`, `""`, `'`, `a"b`, `it's`, `"quoted"`, `\`, "`"}[rand.Intn(8)]
+	case 3:
+		name = []string{".", "..", "a.b", ".leading", "trailing."}[rand.Intn(5)]
+	case 4:
+		// All strings must be NFC normalized as CockroachDB normalizes
+		// identifiers.
+		name = []string{"café", "日本語", "Ünïcödé", "🐓", "straße", "İstanbul", "ελληνικά"}[rand.Intn(7)]
+	case 5:
+		name = []string{" ", " leading", "trailing ", "in side", "tab\tbed"}[rand.Intn(5)]
+	case 6:
+		name = strings.Repeat("x", []int{63, 64, 127, 128, 255}[rand.Intn(5)])
+	}
+
+	if FlipCoin() {
+		return name
+	}
+	return RandomString(name)
+}
+
+// keywords is a selection of SQL keywords, both reserved and unreserved, that
+// are valid identifiers when quoted.
+var keywords = []string{
+	"all",
+	"as",
+	"column",
+	"database",
+	"default",
+	"false",
+	"from",
+	"group",
+	"index",
+	"null",
+	"order",
+	"primary",
+	"schema",
+	"select",
+	"table",
+	"true",
+	"user",
+	"where",
+	"SELECT",
+	"Table",
+}
+
 // Generated with: cat /usr/share/dict/words | rg -so '[a-z]{4,6}' | head -n 70
 var words = []string{
 	"aalii",
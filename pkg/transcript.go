@@ -2,8 +2,14 @@ package pkg
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
 	"reflect"
+	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/chrisseto/scwl/pkg/dag"
@@ -12,39 +18,155 @@ import (
 	"github.com/google/go-cmp/cmp/cmpopts"
 )
 
+// Transcript is a recording of a workload. It may be replayed against a
+// System with Run to reproduce a failure.
 type Transcript struct {
-	Steps []Step
+	// Version is the version of CockroachDB that the transcript was recorded
+	// against.
+	Version string
+	// ReferenceVersion is the version of CockroachDB that produced the
+	// expected states and errors in differential mode. It's empty if they
+	// were produced by the oracle.
+	ReferenceVersion string
+	// Initial is the state prior to the first step.
+	Initial *dag.Graph
+	Steps   []Step
 }
 
 func (t *Transcript) Run(ctx context.Context, sys System) error {
-	for _, step := range t.Steps {
-		if err := sys.Execute(ctx, step.Command); err != nil {
-			return err // TODO Annotate
+	for i, step := range t.Steps {
+		err := sys.Execute(WithSchemaChanger(ctx, step.SchemaChanger), step.Command)
+		if err != nil && step.Error == "" {
+			return errors.Wrapf(err, "step %d%s: %s", i, step.schemaChangerSuffix(), CommandToString(step.Command))
+		}
+		if err == nil && step.Error != "" {
+			return errors.Newf("step %d%s: %s: expected error %q", i, step.schemaChangerSuffix(), CommandToString(step.Command), step.Error)
 		}
 
-		state, err := sys.State(ctx)
-		if err != nil {
-			return err // TODO Annotate
+		if settler, ok := sys.(Settler); ok {
+			if err := settler.Settle(ctx); err != nil {
+				return errors.Wrapf(err, "step %d%s: %s", i, step.schemaChangerSuffix(), CommandToString(step.Command))
+			}
 		}
 
-		opts := []cmp.Option{
-			cmpopts.IgnoreTypes(dag.Node{}),
-			cmp.Transformer("Comparable", func(g *dag.Graph) []dag.CNode {
-				return g.Comparable()
-			}),
+		state, err := sys.State(ctx)
+		if err != nil {
+			return errors.Wrapf(err, "step %d: loading state", i)
 		}
 
-		if diff := cmp.Diff(step.Expected, state, opts...); diff != "" {
-			return errors.Newf("state mismatch: %s", diff)
+		if diff := DiffStates(step.Expected, state); diff != "" {
+			return errors.Newf("step %d%s: %s: state mismatch: %s", i, step.schemaChangerSuffix(), CommandToString(step.Command), diff)
 		}
 	}
 
 	return nil
 }
 
+// DiffStates returns a human readable diff between two state graphs or an
+// empty string if they're equivalent.
+func DiffStates(want, got *dag.Graph) string {
+	opts := []cmp.Option{
+		cmpopts.IgnoreTypes(dag.Node{}),
+		cmp.Transformer("Comparable", func(g *dag.Graph) []dag.CNode {
+			return g.Comparable()
+		}),
+	}
+
+	return cmp.Diff(want, got, opts...)
+}
+
 type Step struct {
 	Command  Command
 	Expected *dag.Graph
+	// Error is the error that the oracle returned when executing Command, if
+	// any. An empty Error indicates that Command is expected to succeed.
+	Error string
+	// SchemaChanger is the schema changer that Command was executed with.
+	SchemaChanger SchemaChanger
+}
+
+// schemaChangerSuffix annotates error messages with the schema changer in use,
+// if one was chosen.
+func (s Step) schemaChangerSuffix() string {
+	if s.SchemaChanger == SchemaChangerDefault {
+		return ""
+	}
+	return fmt.Sprintf(" (%s schema changer)", s.SchemaChanger)
+}
+
+type jsonTranscript struct {
+	Version          string     `json:"version"`
+	ReferenceVersion string     `json:"reference_version,omitempty"`
+	Initial          *dag.Graph `json:"initial"`
+	Steps            []jsonStep `json:"steps"`
+}
+
+type jsonStep struct {
+	Command       string        `json:"command"`
+	Expected      *dag.Graph    `json:"expected"`
+	Error         string        `json:"error,omitempty"`
+	SchemaChanger SchemaChanger `json:"schema_changer,omitempty"`
+}
+
+// MarshalJSON implements [json.Marshaler]. Commands are encoded with
+// [CommandToString].
+func (t *Transcript) MarshalJSON() ([]byte, error) {
+	out := jsonTranscript{
+		Version:          t.Version,
+		ReferenceVersion: t.ReferenceVersion,
+		Initial:          t.Initial,
+		Steps:            make([]jsonStep, len(t.Steps)),
+	}
+
+	for i, step := range t.Steps {
+		out.Steps[i] = jsonStep{
+			Command:       CommandToString(step.Command),
+			Expected:      step.Expected,
+			Error:         step.Error,
+			SchemaChanger: step.SchemaChanger,
+		}
+	}
+
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON implements [json.Unmarshaler]. Each Command is resolved
+// against the expected state of the step prior to it.
+func (t *Transcript) UnmarshalJSON(data []byte) error {
+	var in jsonTranscript
+	if err := json.Unmarshal(data, &in); err != nil {
+		return errors.WithStack(err)
+	}
+
+	if in.Initial == nil {
+		return errors.New("transcript is missing an initial state")
+	}
+
+	t.Version = in.Version
+	t.ReferenceVersion = in.ReferenceVersion
+	t.Initial = in.Initial
+	t.Steps = make([]Step, len(in.Steps))
+
+	prev := in.Initial
+	for i, step := range in.Steps {
+		cmd, err := ParseCommand(prev, step.Command)
+		if err != nil {
+			return errors.Wrapf(err, "step %d", i)
+		}
+
+		if step.Expected == nil {
+			return errors.Newf("step %d is missing an expected state", i)
+		}
+
+		if err := step.SchemaChanger.Validate(); err != nil {
+			return errors.Wrapf(err, "step %d", i)
+		}
+
+		t.Steps[i] = Step{Command: cmd, Expected: step.Expected, Error: step.Error, SchemaChanger: step.SchemaChanger}
+		prev = step.Expected
+	}
+
+	return nil
 }
 
 func WithFullQualifiedName[T dag.INode](name string) dag.Filter[T] {
@@ -53,23 +175,47 @@ func WithFullQualifiedName[T dag.INode](name string) dag.Filter[T] {
 	}
 }
 
+// FullyQualifiedName returns a dot separated path uniquely identifying el
+// within its graph. Each name within the path is escaped by escapeName, so
+// names containing dots are unambiguous. It matches the ids of oracleSchema.
 func FullyQualifiedName(el dag.INode) string {
 	switch n := el.(type) {
 	case *Database:
-		return fmt.Sprintf("%s", n.Name)
+		return escapeName(n.Name)
 	case *Schema:
-		return FullyQualifiedName(n.Database()) + fmt.Sprintf(".%s", n.Name)
+		return FullyQualifiedName(n.Database()) + "." + escapeName(n.Name)
 	case *Table:
-		return FullyQualifiedName(n.Schema()) + fmt.Sprintf(".%s", n.Name)
+		return FullyQualifiedName(n.Schema()) + "." + escapeName(n.Name)
 	case *Column:
-		return FullyQualifiedName(n.Table()) + fmt.Sprintf(".cols.%s", n.Name)
+		return FullyQualifiedName(n.Table()) + ".cols." + escapeName(n.Name)
 	case *Index:
-		return FullyQualifiedName(n.Table()) + fmt.Sprintf(".idxs.%s", n.Name)
+		return FullyQualifiedName(n.Table()) + ".idxs." + escapeName(n.Name)
+	case *ForeignKeyConstraint:
+		return FullyQualifiedName(n.From().Table()) + ".fks." + escapeName(n.Name)
 	default:
 		panic(errors.Newf("unhandled type: %T", el))
 	}
 }
 
+// escapeName backslash escapes backslashes and dots within name. It must
+// agree with the replace expressions of oracleSchema.
+func escapeName(name string) string {
+	return strings.NewReplacer(`\`, `\\`, `.`, `\.`).Replace(name)
+}
+
+// ByFQN returns the T within g with the fully qualified name name. Panics if
+// there isn't exactly one such T. It's used by the output of
+// [CommandToString].
+func ByFQN[T dag.INode](g *dag.Graph, name string) T {
+	return dag.Nodes[T](g, WithFullQualifiedName[T](name)).One()
+}
+
+// CommandToString renders cmd as a Go expression that will reconstruct it,
+// given a *dag.Graph named g. eg:
+//
+//	pkg.RenameTable{Table: pkg.ByFQN[*pkg.Table](g, "defaultdb.public.users"), Name: "people"}
+//
+// The output may be parsed with [ParseCommand].
 func CommandToString(cmd Command) string {
 	var b strings.Builder
 	val := reflect.ValueOf(cmd)
@@ -83,20 +229,194 @@ func CommandToString(cmd Command) string {
 			fmt.Fprintf(&b, ", ")
 		}
 
-		switch v := val.Field(i).Interface().(type) {
-		case dag.Node:
-			continue
+		fmt.Fprintf(&b, "%s: %s", field.Name, valueToString(val.Field(i)))
+	}
+	b.WriteRune('}')
+	return b.String()
+}
 
-		case dag.INode:
-			fmt.Fprintf(&b, "%s: ByFQN(g, %q)", field.Name, FullyQualifiedName(v))
+func valueToString(v reflect.Value) string {
+	if v.Kind() == reflect.Pointer && v.IsNil() {
+		return "nil"
+	}
 
-		case string:
-			fmt.Fprintf(&b, "%s: %q", field.Name, v)
+	switch x := v.Interface().(type) {
+	case dag.INode:
+		return fmt.Sprintf("pkg.ByFQN[%s](g, %q)", typeToString(v.Type()), FullyQualifiedName(x))
 
-		default:
-			fmt.Fprintf(&b, "%s: %v", field.Name, v)
+	case string:
+		return fmt.Sprintf("%q", x)
+	}
+
+	if v.Kind() == reflect.Slice {
+		elems := make([]string, v.Len())
+		for i := range elems {
+			elems[i] = valueToString(v.Index(i))
 		}
+		return fmt.Sprintf("%s{%s}", typeToString(v.Type()), strings.Join(elems, ", "))
 	}
-	b.WriteRune('}')
-	return b.String()
+
+	return fmt.Sprintf("%v", v.Interface())
+}
+
+// typeToString renders t as it would be referenced from outside of this
+// package, eg: *pkg.Table.
+func typeToString(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return "*" + typeToString(t.Elem())
+	case reflect.Slice:
+		return "[]" + typeToString(t.Elem())
+	default:
+		return "pkg." + t.Name()
+	}
+}
+
+// commandStart matches the start of a Command rendered by [CommandToString],
+// eg: "pkg.DropTable{".
+var commandStart = regexp.MustCompile(`pkg\.(\w+)\{`)
+
+// ParseCommand is the inverse of [CommandToString]. Nodes referenced by
+// ByFQN are resolved against g. Any text preceding the Command, such as a
+// log prefix, is ignored. The Command is expected to start with
+// "pkg.<Command>{", where <Command> is one of AllCommands. eg:
+//
+//	2023/08/30 10:00:00 Step 3: pkg.DropTable{Table: pkg.ByFQN[*pkg.Table](g, "defaultdb.public.users")}
+func ParseCommand(g *dag.Graph, s string) (Command, error) {
+	for _, loc := range commandStart.FindAllStringSubmatchIndex(s, -1) {
+		if commandType(s[loc[2]:loc[3]]) != nil {
+			s = s[loc[0]:]
+			break
+		}
+	}
+
+	expr, err := parser.ParseExpr(s)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing %q", s)
+	}
+
+	lit, ok := expr.(*ast.CompositeLit)
+	if !ok {
+		return nil, errors.Newf("expected a composite literal, got %T", expr)
+	}
+
+	sel, ok := lit.Type.(*ast.SelectorExpr)
+	if !ok {
+		return nil, errors.Newf("expected a pkg.Command type, got %T", lit.Type)
+	}
+
+	t := commandType(sel.Sel.Name)
+	if t == nil {
+		return nil, errors.Newf("unknown command %q", sel.Sel.Name)
+	}
+
+	cmd := reflect.New(t).Elem()
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			return nil, errors.Newf("%s: expected keyed fields", t.Name())
+		}
+
+		key, ok := kv.Key.(*ast.Ident)
+		if !ok {
+			return nil, errors.Newf("%s: expected field name, got %T", t.Name(), kv.Key)
+		}
+
+		field := cmd.FieldByName(key.Name)
+		if !field.IsValid() {
+			return nil, errors.Newf("%s: unknown field %q", t.Name(), key.Name)
+		}
+
+		if err := parseValue(g, kv.Value, field); err != nil {
+			return nil, errors.Wrapf(err, "%s.%s", t.Name(), key.Name)
+		}
+	}
+
+	return cmd.Interface(), nil
+}
+
+// commandType returns the type of the Command within AllCommands named name
+// or nil if there isn't one.
+func commandType(name string) reflect.Type {
+	for _, cmd := range AllCommands {
+		if reflect.TypeOf(cmd).Name() == name {
+			return reflect.TypeOf(cmd)
+		}
+	}
+	return nil
+}
+
+func parseValue(g *dag.Graph, expr ast.Expr, out reflect.Value) error {
+	switch e := expr.(type) {
+	case *ast.BasicLit:
+		switch {
+		case e.Kind == token.STRING && out.Kind() == reflect.String:
+			s, err := strconv.Unquote(e.Value)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			out.SetString(s)
+			return nil
+
+		case e.Kind == token.INT && out.Kind() == reflect.Int:
+			i, err := strconv.ParseInt(e.Value, 10, 64)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			out.SetInt(i)
+			return nil
+		}
+
+	case *ast.Ident:
+		if out.Kind() == reflect.Bool && (e.Name == "true" || e.Name == "false") {
+			out.SetBool(e.Name == "true")
+			return nil
+		}
+
+		if out.Kind() == reflect.Pointer && e.Name == "nil" {
+			return nil
+		}
+
+	case *ast.CompositeLit:
+		if out.Kind() != reflect.Slice {
+			break
+		}
+
+		slice := reflect.MakeSlice(out.Type(), len(e.Elts), len(e.Elts))
+		for i, elt := range e.Elts {
+			if err := parseValue(g, elt, slice.Index(i)); err != nil {
+				return err
+			}
+		}
+		out.Set(slice)
+		return nil
+
+	case *ast.CallExpr:
+		// ByFQN(g, "name") or pkg.ByFQN[T](g, "name"). The type parameter, if
+		// present, is ignored in favor of out's type.
+		if len(e.Args) != 2 {
+			break
+		}
+
+		arg, ok := e.Args[1].(*ast.BasicLit)
+		if !ok || arg.Kind != token.STRING {
+			break
+		}
+
+		name, err := strconv.Unquote(arg.Value)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		for _, n := range dag.Nodes[dag.INode](g) {
+			if reflect.TypeOf(n) == out.Type() && FullyQualifiedName(n) == name {
+				out.Set(reflect.ValueOf(n))
+				return nil
+			}
+		}
+
+		return errors.Newf("no %s named %q", out.Type(), name)
+	}
+
+	return errors.Newf("unsupported expression %T for %s", expr, out.Type())
 }
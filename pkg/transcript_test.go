@@ -1,6 +1,13 @@
 package pkg_test
 
 import (
+	"encoding/json"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
 	"testing"
 
 	"github.com/chrisseto/scwl/pkg"
@@ -9,14 +16,18 @@ import (
 )
 
 func TestToString(t *testing.T) {
-	g := dag.New(nil)
+	g := dag.New()
 
 	defaultdb := g.AddNode("1", &pkg.Database{Name: "defaultdb"}).(*pkg.Database)
 	public := g.AddNode("2", &pkg.Schema{Name: "public"}).(*pkg.Schema)
 	users := g.AddNode("3", &pkg.Table{Name: "users"}).(*pkg.Table)
+	id := g.AddNode("4", &pkg.Column{Name: "id"}).(*pkg.Column)
+	email := g.AddNode("5", &pkg.Column{Name: "email"}).(*pkg.Column)
 
 	g.AddEdge(defaultdb, public)
 	g.AddEdge(public, users)
+	g.AddEdge(users, id)
+	g.AddEdge(users, email)
 
 	testCases := []struct {
 		In  pkg.Command
@@ -24,15 +35,92 @@ func TestToString(t *testing.T) {
 	}{
 		{
 			In:  pkg.RenameDatabase{Database: defaultdb, Name: "postgres"},
-			Out: `pkg.RenameDatabase{Database: ByFQN(g, "defaultdb"), Name: "postgres"}`,
+			Out: `pkg.RenameDatabase{Database: pkg.ByFQN[*pkg.Database](g, "defaultdb"), Name: "postgres"}`,
 		},
 		{
 			In:  pkg.RenameTable{Table: users, Name: "people"},
-			Out: `pkg.RenameTable{Table: ByFQN(g, "defaultdb.public.users"), Name: "people"}`,
+			Out: `pkg.RenameTable{Table: pkg.ByFQN[*pkg.Table](g, "defaultdb.public.users"), Name: "people"}`,
+		},
+		{
+			In:  pkg.CreateIndex{Table: users, Columns: []*pkg.Column{id, email}, Name: `"quoted"`, Unique: true},
+			Out: `pkg.CreateIndex{Table: pkg.ByFQN[*pkg.Table](g, "defaultdb.public.users"), Columns: []*pkg.Column{pkg.ByFQN[*pkg.Column](g, "defaultdb.public.users.cols.id"), pkg.ByFQN[*pkg.Column](g, "defaultdb.public.users.cols.email")}, Name: "\"quoted\"", Unique: true}`,
 		},
 	}
 
 	for _, tc := range testCases {
 		require.Equal(t, tc.Out, pkg.CommandToString(tc.In))
+
+		parsed, err := pkg.ParseCommand(g, "2023/08/30 10:00:00 Step 3: "+tc.Out)
+		require.NoError(t, err)
+		require.Equal(t, tc.In, parsed)
 	}
+
+	require.Equal(t, users, pkg.ByFQN[*pkg.Table](g, "defaultdb.public.users"))
+
+	// Only "pkg.<Command>{" marks the start of a Command, not any "pkg.".
+	parsed, err := pkg.ParseCommand(g, `scwl/pkg.go:12: Step 3: pkg.DropTable{Table: pkg.ByFQN[*pkg.Table](g, "defaultdb.public.users")}`)
+	require.NoError(t, err)
+	require.Equal(t, pkg.DropTable{Table: users}, parsed)
+
+	// The older, non-generic, form of ByFQN is also accepted.
+	parsed, err = pkg.ParseCommand(g, `pkg.DropTable{Table: ByFQN(g, "defaultdb.public.users")}`)
+	require.NoError(t, err)
+	require.Equal(t, pkg.DropTable{Table: users}, parsed)
+
+	_, err = pkg.ParseCommand(g, `pkg.DropTable{Table: pkg.ByFQN[*pkg.Table](g, "defaultdb.public.posts")}`)
+	require.EqualError(t, err, `DropTable.Table: no *pkg.Table named "defaultdb.public.posts"`)
+
+	_, err = pkg.ParseCommand(g, `pkg.TruncateTable{}`)
+	require.EqualError(t, err, `unknown command "TruncateTable"`)
+}
+
+func TestTranscriptJSON(t *testing.T) {
+	initial := dag.New()
+	defaultdb := initial.AddNode("1", &pkg.Database{Name: "defaultdb"}).(*pkg.Database)
+
+	renamed := dag.New()
+	renamed.AddNode("1", &pkg.Database{Name: "`weird`"})
+
+	transcript := &pkg.Transcript{
+		Version:          "v23.1.0",
+		ReferenceVersion: "v22.2.0",
+		Initial:          initial,
+		Steps: []pkg.Step{
+			{Command: pkg.RenameDatabase{Database: defaultdb, Name: "`weird`"}, Expected: renamed, SchemaChanger: pkg.SchemaChangerDeclarative},
+			{Command: pkg.CreateDatabase{Name: "`weird`"}, Expected: renamed, Error: "database already exists"},
+		},
+	}
+
+	encoded, err := json.Marshal(transcript)
+	require.NoError(t, err)
+
+	var decoded pkg.Transcript
+	require.NoError(t, json.Unmarshal(encoded, &decoded))
+	require.Equal(t, "v23.1.0", decoded.Version)
+	require.Equal(t, "v22.2.0", decoded.ReferenceVersion)
+	require.Len(t, decoded.Steps, 2)
+	require.Equal(t, pkg.SchemaChangerDeclarative, decoded.Steps[0].SchemaChanger)
+	require.Empty(t, pkg.DiffStates(initial, decoded.Initial))
+	require.Empty(t, pkg.DiffStates(renamed, decoded.Steps[0].Expected))
+	require.Equal(t, pkg.CommandToString(transcript.Steps[0].Command), pkg.CommandToString(decoded.Steps[0].Command))
+	require.Empty(t, decoded.Steps[0].Error)
+	require.Equal(t, "database already exists", decoded.Steps[1].Error)
+
+	var b strings.Builder
+	require.NoError(t, transcript.WriteGoTest(&b, pkg.GoTestOptions{Package: "regression", Name: "TestRepro"}))
+	require.Contains(t, b.String(), "package regression")
+	require.Contains(t, b.String(), "func TestRepro(t *testing.T) {")
+	require.Contains(t, b.String(), `testserver.CustomVersionOpt("v23.1.0")`)
+	require.Contains(t, b.String(), "//	0: pkg.RenameDatabase{")
+
+	// The generated test must compile.
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "repro_test.go", b.String(), parser.ParseComments)
+	require.NoError(t, err)
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	_, err = conf.Check("regression", fset, []*ast.File{file}, nil)
+	require.NoError(t, err)
+
+	err = transcript.WriteGoTest(&b, pkg.GoTestOptions{Package: "regression", Name: "Repro"})
+	require.EqualError(t, err, `test name "Repro" must begin with Test`)
 }
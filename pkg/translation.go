@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"reflect"
+	"strings"
 	"text/template"
 
 	"github.com/chrisseto/scwl/pkg/dag"
@@ -42,100 +43,123 @@ type Translation struct {
 
 var translations = map[reflect.Type]Translation{
 	reflect.TypeOf(CreateDatabase{}): {
-		DDL: `CREATE DATABASE "{{ .Name }}"`,
+		DDL: `CREATE DATABASE {{ .Name | ident }}`,
 		DML: `
-			INSERT INTO databases(name) VALUES ('{{ .Name }}');
-			INSERT INTO schemas(database_id, name) VALUES ('{{ .Name }}', 'public');
+			INSERT INTO databases(name) VALUES ({{ .Name | lit }});
+			INSERT INTO schemas(database_id, name) VALUES ({{ .Name | esc | lit }}, 'public');
 		`,
 	},
 	reflect.TypeOf(DropDatabase{}): {
-		DDL: `DROP DATABASE "{{ .Database.Name }}"`,
-		DML: `DELETE FROM databases WHERE name = '{{ .Name }}'`,
+		DDL: `DROP DATABASE {{ .Database | fqnq }} CASCADE`,
+		DML: `DELETE FROM databases WHERE id = {{ .Database | fqn | lit }}`,
 	},
 	reflect.TypeOf(CreateSchema{}): {
-		DDL: `CREATE SCHEMA "{{.Database.Name }}"."{{.Name}}"`,
-		DML: `INSERT INTO schemas(database_id, name) VALUES ('{{.Database.Name}}', '{{.Name}}')`,
+		DDL: `CREATE SCHEMA {{ .Database | fqnq }}.{{ .Name | ident }}`,
+		DML: `INSERT INTO schemas(database_id, name) VALUES ({{ .Database | fqn | lit }}, {{ .Name | lit }})`,
 	},
 	reflect.TypeOf(DropSchema{}): {
-		DDL: `DROP SCHEMA {{.Database | fqnq}} CASCADE`,
-		DML: `DELETE FROM schemas WHERE id = '{{ .Database | fqn }}'`,
+		DDL: `DROP SCHEMA {{ .Schema | fqnq }} CASCADE`,
+		DML: `DELETE FROM schemas WHERE id = {{ .Schema | fqn | lit }}`,
 	},
 	reflect.TypeOf(CreateTable{}): {
-		DDL: `CREATE TABLE {{ .Schema | fqnq }}."{{.Name}}" ()`,
-		DML: `INSERT INTO tables(schema_id, name) VALUES ('{{ .Schema | fqn }}', '{{.Name}}')`,
+		DDL: `CREATE TABLE {{ .Schema | fqnq }}.{{ .Name | ident }} ()`,
+		DML: `INSERT INTO tables(schema_id, name) VALUES ({{ .Schema | fqn | lit }}, {{ .Name | lit }})`,
 	},
 	reflect.TypeOf(DropTable{}): {
-		DDL: `DROP TABLE {{ .Table | fqnq }}`,
-		DML: `DELETE FROM tables WHERE id = '{{ .Table | fqn}}'`,
+		DDL: `DROP TABLE {{ .Table | fqnq }} CASCADE`,
+		DML: `DELETE FROM tables WHERE id = {{ .Table | fqn | lit }}`,
 	},
 	reflect.TypeOf(AddColumn{}): {
-		DDL: `ALTER TABLE {{ .Table | fqnq }} ADD COLUMN "{{ .Name }}" TEXT NOT NULL`,
-		DML: `INSERT INTO columns(table_id, name, nullable) VALUES ('{{ .Table | fqn }}', '{{ .Name }}', false)`,
+		DDL: `ALTER TABLE {{ .Table | fqnq }} ADD COLUMN {{ .Name | ident }} TEXT NOT NULL`,
+		DML: `INSERT INTO columns(table_id, name, nullable) VALUES ({{ .Table | fqn | lit }}, {{ .Name | lit }}, false)`,
 	},
 	reflect.TypeOf(DropColumn{}): {
-		DDL: `ALTER TABLE {{ .Table | fqnq }} DROP COLUMN "{{ .Column.Name }}"`,
-		DML: `DELETE FROM columns WHERE id = '{{ .Column | fqn }}'`,
+		DDL: `ALTER TABLE {{ .Column.Table | fqnq }} DROP COLUMN {{ .Column.Name | ident }} CASCADE`,
+		// Dropping a column drops any index that contains it.
+		DML: `
+			DELETE FROM indexes WHERE id IN (SELECT index_id FROM index_columns WHERE column_id = {{ .Column | fqn | lit }});
+			DELETE FROM columns WHERE id = {{ .Column | fqn | lit }};
+		`,
 	},
 	reflect.TypeOf(CreateIndex{}): {
-		DDL: `CREATE {{if .Unique}}UNIQUE{{ end }} INDEX "{{ .Name }}"  ON {{ .Table | fqnq }} (
+		DDL: `CREATE {{if .Unique}}UNIQUE{{ end }} INDEX {{ .Name | ident }}  ON {{ .Table | fqnq }} (
 			{{range $i, $column := .Columns}}
 				{{if gt $i 0 }},{{ end }}
-				"{{ $column.Name }}"
+				{{ $column.Name | ident }}
 			{{end}}
 		)`,
 		DML: `
-			INSERT INTO indexes(table_id, name, "unique") VALUES ('{{ .Table | fqn }}', '{{ .Name }}', {{ .Unique }});
+			INSERT INTO indexes(table_id, name, "unique") VALUES ({{ .Table | fqn | lit }}, {{ .Name | lit }}, {{ .Unique }});
 			{{range $i, $column := .Columns}}
-				INSERT INTO index_columns(index_id, column_id) VALUES ('{{ .Table | fqn }}.idxs.{{ $.Name }}', '{{ $column | fqn }}');
+				INSERT INTO index_columns(index_id, column_id) VALUES ({{ printf "%s.idxs.%s" (fqn $.Table) (esc $.Name) | lit }}, {{ $column | fqn | lit }});
 			{{end}}
 		`,
 	},
 	reflect.TypeOf(CreateForeignKeyConstraint{}): {
-		DDL: `ALTER TABLE {{ .From.Table | fqnq }} ADD CONSTRAINT "{{ .Name }}" FOREIGN KEY ("{{ .From.Name }}") REFERENCES {{ .To.Table | fqnq }} ({{ .To.Name }})`,
+		DDL: `ALTER TABLE {{ .From.Table | fqnq }} ADD CONSTRAINT {{ .Name | ident }} FOREIGN KEY ({{ .From.Name | ident }}) REFERENCES {{ .To.Table | fqnq }} ({{ .To.Name | ident }})`,
 		DML: ` INSERT INTO fk_constraints(to_id, from_id, name) VALUES (
-			'{{ .To | fqn }}',
-			'{{ .From | fqn }}',
-			'{{ .Name }}'
+			{{ .To | fqn | lit }},
+			{{ .From | fqn | lit }},
+			{{ .Name | lit }}
 		)`,
 	},
 	reflect.TypeOf(DropForeignKeyConstraint{}): {
-		DDL: `ALTER TABLE {{ .ForeignKeyConstraint.From.Table | fqnq }} DROP CONSTRAINT "{{ .ForeignKeyConstraint.Name }}"`,
+		DDL: `ALTER TABLE {{ .ForeignKeyConstraint.From.Table | fqnq }} DROP CONSTRAINT {{ .ForeignKeyConstraint.Name | ident }}`,
 		// TODO This is probably buggy
-		DML: `DELETE FROM fk_constraints WHERE name = '{{ .ForeignKeyConstraint.Name }}'`,
+		DML: `DELETE FROM fk_constraints WHERE name = {{ .ForeignKeyConstraint.Name | lit }}`,
 	},
 	reflect.TypeOf(DropIndex{}): {
-		DDL: `DROP INDEX {{ .Index.Table | fqnq }}@"{{ .Index.Name }}" CASCADE`,
-		DML: `DELETE FROM indexes WHERE id = '{{ .Index | fqn }}'`,
+		DDL: `DROP INDEX {{ .Index.Table | fqnq }}@{{ .Index.Name | ident }} CASCADE`,
+		DML: `DELETE FROM indexes WHERE id = {{ .Index | fqn | lit }}`,
 	},
 	reflect.TypeOf(RenameDatabase{}): {
-		DDL: `ALTER DATABASE {{ .Database | fqnq }} RENAME TO "{{ .Name }}"`,
-		DML: `UPDATE databases SET name = '{{ .Name }}' WHERE id = '{{ .Database | fqn }}'`,
+		DDL: `ALTER DATABASE {{ .Database | fqnq }} RENAME TO {{ .Name | ident }}`,
+		DML: `UPDATE databases SET name = {{ .Name | lit }} WHERE id = {{ .Database | fqn | lit }}`,
 	},
 	reflect.TypeOf(RenameSchema{}): {
-		DDL: `ALTER SCHEMA {{ .Schema | fqnq }} RENAME TO "{{ .Name }}"`,
-		DML: `UPDATE schemas SET name = '{{ .Name }}' WHERE id = '{{ .Schema | fqn }}'`,
+		DDL: `ALTER SCHEMA {{ .Schema | fqnq }} RENAME TO {{ .Name | ident }}`,
+		DML: `UPDATE schemas SET name = {{ .Name | lit }} WHERE id = {{ .Schema | fqn | lit }}`,
 	},
 	reflect.TypeOf(RenameTable{}): {
-		DDL: `ALTER TABLE {{ .Table | fqnq }} RENAME TO "{{ .Name }}"`,
-		DML: `UPDATE tables SET name = '{{ .Name }}' WHERE id = '{{ .Table | fqn }}'`,
+		DDL: `ALTER TABLE {{ .Table | fqnq }} RENAME TO {{ .Name | ident }}`,
+		DML: `UPDATE tables SET name = {{ .Name | lit }} WHERE id = {{ .Table | fqn | lit }}`,
 	},
 }
 
+// QuoteIdentifier returns s as a delimited SQL identifier. Double quotes
+// within s are doubled.
+func QuoteIdentifier(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+// QuoteLiteral returns s as a SQL string literal. Single quotes within s are
+// doubled.
+func QuoteLiteral(s string) string {
+	return `'` + strings.ReplaceAll(s, `'`, `''`) + `'`
+}
+
+// QuotedName returns the fully qualified, delimited, SQL name of a Database,
+// Schema or Table.
+func QuotedName(n dag.INode) string {
+	switch n := n.(type) {
+	case *Database:
+		return QuoteIdentifier(n.Name)
+	case *Schema:
+		return QuotedName(n.Database()) + "." + QuoteIdentifier(n.Name)
+	case *Table:
+		return QuotedName(n.Schema()) + "." + QuoteIdentifier(n.Name)
+	default:
+		panic(fmt.Sprintf("unhandled type: %T", n))
+	}
+}
+
 func Tpl(body string, vars any) string {
 	tmpl, err := template.New("").Funcs(template.FuncMap{
-		"fqn": FullyQualifiedName,
-		"fqnq": func(sn dag.INode) string {
-			switch n := sn.(type) {
-			case *Database:
-				return fmt.Sprintf("%q", n.Name)
-			case *Schema:
-				return fmt.Sprintf("%q.%q", n.Database().Name, n.Name)
-			case *Table:
-				return fmt.Sprintf("%q.%q.%q", n.Schema().Database().Name, n.Schema().Name, n.Name)
-			default:
-				panic(fmt.Sprintf("unhandled type: %T", sn))
-			}
-		},
+		"fqn":   FullyQualifiedName,
+		"esc":   escapeName,
+		"ident": QuoteIdentifier,
+		"lit":   QuoteLiteral,
+		"fqnq":  QuotedName,
 	}).Parse(body)
 	if err != nil {
 		panic(err)
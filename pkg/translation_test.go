@@ -0,0 +1,124 @@
+package pkg_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/chrisseto/scwl/pkg"
+	"github.com/chrisseto/scwl/pkg/dag"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDropTranslations(t *testing.T) {
+	g := dag.New()
+
+	db := g.AddNode("1", &pkg.Database{Name: "db"}).(*pkg.Database)
+	public := g.AddNode("2", &pkg.Schema{Name: "public"}).(*pkg.Schema)
+	schema := g.AddNode("3", &pkg.Schema{Name: "s"}).(*pkg.Schema)
+	users := g.AddNode("4", &pkg.Table{Name: "users"}).(*pkg.Table)
+	email := g.AddNode("5", &pkg.Column{Name: "email"}).(*pkg.Column)
+
+	g.AddEdge(db, public)
+	g.AddEdge(db, schema)
+	g.AddEdge(schema, users)
+	g.AddEdge(users, email)
+
+	for _, tc := range []struct {
+		cmd pkg.Command
+		ddl string
+		dml []string
+	}{
+		{
+			cmd: pkg.DropDatabase{Database: db},
+			ddl: `DROP DATABASE "db" CASCADE`,
+			dml: []string{`DELETE FROM databases WHERE id = 'db'`},
+		},
+		{
+			cmd: pkg.DropSchema{Schema: schema},
+			ddl: `DROP SCHEMA "db"."s" CASCADE`,
+			dml: []string{`DELETE FROM schemas WHERE id = 'db.s'`},
+		},
+		{
+			cmd: pkg.DropTable{Table: users},
+			ddl: `DROP TABLE "db"."s"."users" CASCADE`,
+			dml: []string{`DELETE FROM tables WHERE id = 'db.s.users'`},
+		},
+		{
+			// Indexes containing the column are dropped along with it.
+			cmd: pkg.DropColumn{Column: email},
+			ddl: `ALTER TABLE "db"."s"."users" DROP COLUMN "email" CASCADE`,
+			dml: []string{
+				`DELETE FROM indexes WHERE id IN (SELECT index_id FROM index_columns WHERE column_id = 'db.s.users.cols.email');`,
+				`DELETE FROM columns WHERE id = 'db.s.users.cols.email';`,
+			},
+		},
+	} {
+		require.Equal(t, tc.ddl, pkg.AsDDL(tc.cmd))
+
+		var dml []string
+		for _, line := range strings.Split(pkg.AsDML(tc.cmd), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				dml = append(dml, line)
+			}
+		}
+		require.Equal(t, tc.dml, dml)
+	}
+
+	// Public schemas can't be dropped.
+	onlyDropSchema := pkg.Profile{Name: "drop-schema", Weights: map[string]int{}}
+	for _, cmd := range pkg.AllCommands {
+		onlyDropSchema.Weights[reflect.TypeOf(cmd).Name()] = 0
+	}
+	onlyDropSchema.Weights["DropSchema"] = 1
+
+	for i := 0; i < 100; i++ {
+		cmd, err := pkg.GenerateCommand(&onlyDropSchema, g)
+		require.NoError(t, err)
+		require.Equal(t, pkg.DropSchema{Schema: schema}, cmd)
+	}
+}
+
+func TestTranslationQuoting(t *testing.T) {
+	g := dag.New()
+
+	db := g.AddNode("1", &pkg.Database{Name: `my "db"`}).(*pkg.Database)
+	schema := g.AddNode("2", &pkg.Schema{Name: "a.b"}).(*pkg.Schema)
+	table := g.AddNode("3", &pkg.Table{Name: "it's"}).(*pkg.Table)
+
+	g.AddEdge(db, schema)
+	g.AddEdge(schema, table)
+
+	cmd := pkg.RenameTable{Table: table, Name: `"select"`}
+
+	require.Equal(t, `ALTER TABLE "my ""db"""."a.b"."it's" RENAME TO """select"""`, pkg.AsDDL(cmd))
+	require.Equal(t, `UPDATE tables SET name = '"select"' WHERE id = 'my "db".a\.b.it''s'`, pkg.AsDML(cmd))
+}
+
+func TestFullyQualifiedNameDots(t *testing.T) {
+	g := dag.New()
+
+	db := g.AddNode("db", &pkg.Database{Name: "db"})
+	for _, path := range [][2]string{{"a.b", "c"}, {"a", "b.c"}, {`a\`, "b.c"}, {`a\.b`, "c"}} {
+		schema := g.AddNode(path[0], &pkg.Schema{Name: path[0]})
+		g.AddEdge(db, schema)
+		g.AddEdge(schema, g.AddNode(path[0]+"/"+path[1], &pkg.Table{Name: path[1]}))
+	}
+
+	seen := map[string]bool{}
+	for _, table := range dag.Nodes[*pkg.Table](g) {
+		fqn := pkg.FullyQualifiedName(table)
+		require.False(t, seen[fqn], "%s is ambiguous", fqn)
+		seen[fqn] = true
+	}
+	require.True(t, seen[`db.a\.b.c`])
+}
+
+func TestRandomName(t *testing.T) {
+	taken := []string{"select", `"`, "."}
+	for i := 0; i < 1000; i++ {
+		name := pkg.RandomName(true, taken...)
+		require.NotEmpty(t, name)
+		require.NotContains(t, taken, name)
+	}
+}
@@ -0,0 +1,390 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/chrisseto/scwl/pkg"
+	"github.com/chrisseto/scwl/pkg/dag"
+	"github.com/cockroachdb/cockroach-go/v2/testserver"
+	"github.com/cockroachdb/errors"
+)
+
+// run generates a random workload and executes it against both a reference,
+// the oracle by default, and the SUT, asserting that their states match after
+// every step.
+func run(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	graphDir := flags.String("graph-dir", "", "if set, DOT, Mermaid and JSON renderings of mismatched states are written to this directory")
+	profileName := flags.String("profile", pkg.DefaultProfile.Name, "name of the workload profile to run")
+	profilesPath := flags.String("profiles", "", "path to a YAML or JSON file of additional workload profiles")
+	candidates := flags.Int("coverage-candidates", 4, "number of candidate commands to generate per step, the least covered is executed; 1 disables coverage guidance")
+	version := flags.String("version", defaultVersion, "version of CockroachDB to run as the SUT")
+	transcriptPath := flags.String("transcript", "", "if set, the executed steps are recorded to this file as a JSON transcript")
+	referenceName := flags.String("reference", "oracle", "system to compare the SUT against, either oracle or a version of CockroachDB to differentially test against")
+	schemaChangerName := flags.String("schema-changer", "", "schema changer the SUT executes commands with: legacy, declarative or random (per command); defaults to the profile's")
+	referenceSchemaChangerName := flags.String("reference-schema-changer", "", "schema changer the reference executes commands with in differential mode")
+	nodes := flags.Int("nodes", 1, "number of nodes in the SUT cluster, 1 or 3 to 5")
+	chaosRate := flags.Float64("chaos", 0, "probability of restarting a SUT node before or during each command, requires -nodes of 3 or more")
+	jobFaultRate := flags.Float64("job-faults", 0, "probability of pausing, resuming or cancelling the schema change job of each command")
+	upgradeTo := flags.String("upgrade-to", "", "if set, the SUT is upgraded node by node to this version during the workload and then finalized")
+	cacheDir := flags.String("cache-dir", "", "directory of cockroach-<version> binaries to use instead of downloading")
+	sample := flags.Bool("sample", false, "observe the SUT from a second connection while each command executes, asserting that it's only ever seen in the before or after state")
+	crossCheck := flags.Bool("cross-check", false, "after each command, compare the SUT's state as seen through information_schema, pg_catalog and SHOW CREATE to its state from crdb_internal")
+	roundTrip := flags.Bool("round-trip", false, "after each command, recreate every database from its SHOW CREATE output in a scratch database and compare the two")
+	validateDescriptors := flags.Bool("validate-descriptors", false, "after each command, check crdb_internal.invalid_objects and the SUT's descriptors for dangling back-references and namespace entries")
+	backupRestoreRate := flags.Float64("backup-restore", 0, "probability of backing up a random database after each command, restoring it under a new name and comparing it to the original")
+	changefeeds := flags.Bool("changefeeds", false, "watch the table affected by each command with a changefeed with schema_change_policy = 'stop' and schema_change_events = 'column_changes', asserting that it stops exactly when the command adds or drops a column or drops the table")
+	settleTimeout := flags.Duration("settle-timeout", pkg.DefaultSettleTimeout, "how long to wait for schema change jobs to settle after each command before reporting them as hung")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	profile, err := loadProfile(*profileName, *profilesPath)
+	if err != nil {
+		return err
+	}
+
+	schemaChanger := profile.SchemaChanger
+	if *schemaChangerName != "" {
+		schemaChanger = pkg.SchemaChanger(*schemaChangerName)
+	}
+	referenceSchemaChanger := pkg.SchemaChanger(*referenceSchemaChangerName)
+	for _, s := range []pkg.SchemaChanger{schemaChanger, referenceSchemaChanger} {
+		if err := s.Validate(); err != nil {
+			return err
+		}
+	}
+
+	// In differential mode, the reference is another version of CockroachDB
+	// rather than the oracle. Either way, the outcome of each command, success
+	// or failure, must match between the reference and the SUT.
+	differential := *referenceName != "oracle"
+
+	if *nodes != 1 && (*nodes < testserverNodes || *nodes > 5) {
+		return errors.Newf("-nodes must be 1 or 3 to 5, got %d", *nodes)
+	}
+	if (*chaosRate > 0 || *jobFaultRate > 0) && differential {
+		return errors.New("-chaos and -job-faults are only supported against the oracle")
+	}
+	if *sample && (*chaosRate > 0 || *jobFaultRate > 0) {
+		return errors.New("-sample can't be combined with -chaos or -job-faults")
+	}
+	if *changefeeds && (*sample || *chaosRate > 0 || *jobFaultRate > 0) {
+		return errors.New("-changefeeds can't be combined with -sample, -chaos or -job-faults")
+	}
+
+	sutCfg := clusterConfig{Nodes: *nodes}
+	sutVersion := versionOpt(*cacheDir, *version)
+	if *nodes > testserverNodes {
+		// Nodes beyond testserver's are run from the same binary.
+		if sutCfg.Binary, err = binaryPath(*cacheDir, *version); err != nil {
+			return err
+		}
+		sutVersion = testserver.CockroachBinaryPathOpt(sutCfg.Binary)
+	}
+
+	var sutOpts []testserver.TestServerOpt
+	if *chaosRate > 0 {
+		// In memory stores don't survive a restart.
+		sutOpts = append(sutOpts, testserver.StoreOnDiskOpt())
+	}
+	if *backupRestoreRate > 0 {
+		// Backups are written to nodelocal storage, which is disabled unless
+		// an external IO directory is provided.
+		dir, err := os.MkdirTemp("", "scwl-backups")
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		defer os.RemoveAll(dir)
+		sutCfg.ExternalIODir = dir
+		sutOpts = append(sutOpts, testserver.ExternalIODirOpt(dir))
+	}
+	if *upgradeTo != "" {
+		path, err := binaryPath(*cacheDir, *upgradeTo)
+		if err != nil {
+			return err
+		}
+		sutCfg.UpgradeBinary = path
+		opts, err := upgradeOpts(*nodes, path)
+		if err != nil {
+			return err
+		}
+		sutOpts = append(sutOpts, opts...)
+	}
+
+	sut, sutTS := NewSUT(ctx, sutVersion, sutCfg, sutOpts...)
+	var reference pkg.System
+	if differential {
+		reference, _ = NewSUT(ctx, versionOpt(*cacheDir, *referenceName), clusterConfig{Nodes: 1})
+	} else {
+		reference = NewOracle(ctx)
+	}
+	logger := log.Default()
+
+	var monkey *chaosMonkey
+	if *chaosRate > 0 {
+		if monkey, err = newChaosMonkey(sutTS, *chaosRate, logger); err != nil {
+			return err
+		}
+	}
+
+	var upgrade *upgrader
+	if *upgradeTo != "" {
+		if upgrade, err = newUpgrader(ctx, sutTS, *nodes, logger); err != nil {
+			return err
+		}
+	}
+
+	if *changefeeds {
+		db, err := openSystemDB(sutTS)
+		if err != nil {
+			return err
+		}
+		if _, err := db.ExecContext(ctx, `SET CLUSTER SETTING kv.rangefeed.enabled = true`); err != nil {
+			return errors.WithStack(err)
+		}
+		db.Close()
+	}
+
+	var faulter *jobFaulter
+	if *jobFaultRate > 0 {
+		if faulter, err = newJobFaulter(sutTS, *jobFaultRate, logger); err != nil {
+			return err
+		}
+	}
+
+	seed := time.Now().UnixNano()
+	rand.Seed(seed)
+
+	iterations := 500
+
+	log.Printf("Iterations: %d, Seed: %d, Profile: %s, Reference: %s, Schema Changer: %q, Upgrade To: %q", iterations, seed, profile.Name, *referenceName, schemaChanger, *upgradeTo)
+
+	state := MustT(reference.State(ctx))
+	coverage := pkg.NewCoverage()
+	transcript := &pkg.Transcript{Version: *version, Initial: state}
+	if differential {
+		transcript.ReferenceVersion = *referenceName
+	}
+
+	writeTranscript := func() {
+		if *transcriptPath == "" {
+			return
+		}
+		encoded, err := json.MarshalIndent(transcript, "", "\t")
+		if err == nil {
+			err = os.WriteFile(*transcriptPath, encoded, 0o644)
+		}
+		if err != nil {
+			logger.Printf("failed to write transcript: %v", err)
+		}
+	}
+
+	// log.Fatalf skips deferred calls, so write the transcript and report
+	// coverage before exiting.
+	fatalf := func(format string, args ...any) {
+		writeTranscript()
+		coverage.Report(os.Stderr, profile)
+		log.Fatalf(format, args...)
+	}
+
+	defer func() {
+		ctx := context.Background()
+
+		writeTranscript()
+		coverage.Report(os.Stderr, profile)
+
+		state = MustT(reference.State(ctx))
+		sutState := MustT(sut.State(ctx))
+		logger.Printf("\tSUT State: %s", sutState.String())
+		logger.Printf("\tReference State: %s", state.String())
+	}()
+
+	// settle waits for the asynchronous work of sys, if any, to complete so
+	// that it doesn't race the comparison of states. Hung jobs are fatal.
+	settle := func(sys pkg.System) {
+		settler, ok := sys.(pkg.Settler)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, *settleTimeout)
+		defer cancel()
+
+		if err := settler.Settle(ctx); err != nil {
+			fatalf("Failed to settle!\n%v", err)
+		}
+	}
+
+	for i := 0; i < iterations; i++ {
+		// Upgrade phases are spread evenly throughout the workload. As
+		// upgrades may migrate descriptors, states are compared after each.
+		if upgrade != nil && !upgrade.Done() && i > 0 && i%(iterations/(upgrade.Steps()+1)) == 0 {
+			phase := MustT(upgrade.Next(ctx))
+			settle(sut)
+			if diff := pkg.DiffStates(state, MustT(sut.State(ctx))); diff != "" {
+				fatalf("State Mismatch after %s!\n%s", phase, diff)
+			}
+		}
+
+		cmd := MustT(coverage.Generate(profile, state, *candidates))
+		coverage.Record(cmd)
+
+		logger.Printf("Step %d: %s", i, pkg.CommandToString(cmd))
+
+		// Schema changers are resolved here, rather than by the SUT, so that
+		// the choice may be recorded.
+		sutSchemaChanger := schemaChanger.Resolve()
+		refSchemaChanger := referenceSchemaChanger.Resolve()
+		if sutSchemaChanger != pkg.SchemaChangerDefault || refSchemaChanger != pkg.SchemaChangerDefault {
+			logger.Printf("\tSchema Changers: SUT %q, Reference %q", sutSchemaChanger, refSchemaChanger)
+		}
+
+		var referenceErr, sutErr error
+		var changefeedEvent pkg.ChangefeedEvent
+		applied := true
+		if monkey == nil && faulter == nil {
+			referenceErr = reference.Execute(pkg.WithSchemaChanger(ctx, refSchemaChanger), cmd)
+			if *sample {
+				var err error
+				sutErr, err = sut.(pkg.Sampler).ExecuteSampled(pkg.WithSchemaChanger(ctx, sutSchemaChanger), cmd)
+				if err != nil {
+					fatalf("Intermediate State Violation! (%q schema changer)\n%v", sutSchemaChanger, err)
+				}
+			} else if *changefeeds {
+				var err error
+				changefeedEvent, sutErr, err = sut.(pkg.ChangefeedWatcher).ExecuteWatched(pkg.WithSchemaChanger(ctx, sutSchemaChanger), cmd)
+				if err != nil {
+					fatalf("Changefeed Failure! (%q schema changer)\n%v", sutSchemaChanger, err)
+				}
+			} else {
+				sutErr = sut.Execute(pkg.WithSchemaChanger(ctx, sutSchemaChanger), cmd)
+			}
+
+			settle(reference)
+			settle(sut)
+		} else {
+			// Under faults, the SUT may return an error, eg: a result is
+			// ambiguous or a job was cancelled, so the SUT is executed first.
+			// If the outcome of its jobs is known, the oracle is executed if
+			// they all succeeded. Otherwise, the oracle is only executed if
+			// the SUT's state has changed.
+			execute := func() error {
+				return sut.Execute(pkg.WithSchemaChanger(ctx, sutSchemaChanger), cmd)
+			}
+			if monkey != nil {
+				inner := execute
+				execute = func() error { return MustT(monkey.Around(ctx, inner)) }
+			}
+
+			var succeeded, found bool
+			if faulter != nil {
+				var err error
+				if sutErr, succeeded, found, err = faulter.Around(ctx, execute); err != nil {
+					panic(err)
+				}
+			} else {
+				sutErr = execute()
+			}
+
+			settle(sut)
+
+			switch {
+			case found:
+				applied = succeeded
+			case sutErr != nil:
+				applied = pkg.DiffStates(state, MustT(sut.State(ctx))) != ""
+			}
+			if sutErr != nil {
+				logger.Printf("\tSUT error under faults (applied: %t): %v", applied, sutErr)
+			}
+			sutErr = nil
+
+			if applied {
+				referenceErr = reference.Execute(pkg.WithSchemaChanger(ctx, refSchemaChanger), cmd)
+			} else if diff := pkg.DiffStates(state, MustT(sut.State(ctx))); diff != "" {
+				// Commands that weren't applied, eg: their job was cancelled,
+				// must leave no trace in the SUT.
+				fatalf("Unapplied Command Changed State!\n%s", diff)
+			}
+		}
+
+		state = MustT(reference.State(ctx))
+		sutState := MustT(sut.State(ctx))
+
+		// Commands that weren't applied are omitted from the transcript as
+		// replaying them without chaos would apply them.
+		if applied {
+			step := pkg.Step{Command: cmd, Expected: state, SchemaChanger: sutSchemaChanger}
+			if referenceErr != nil {
+				step.Error = referenceErr.Error()
+			}
+			transcript.Steps = append(transcript.Steps, step)
+		}
+
+		if (referenceErr == nil) != (sutErr == nil) {
+			fatalf("Outcome Mismatch!\n\tSUT (%q schema changer): %v\n\tReference (%q schema changer): %v", sutSchemaChanger, sutErr, refSchemaChanger, referenceErr)
+		}
+
+		if diff := pkg.DiffStates(state, sutState); diff != "" {
+			logger.Printf("\tSUT State: %s", sutState.String())
+			logger.Printf("\tReference State: %s", state.String())
+			if *graphDir != "" {
+				onlyReference, onlySUT := pkg.Mismatched(state, sutState)
+				if err := writeGraphs(*graphDir, *referenceName, state, onlyReference); err != nil {
+					logger.Printf("failed to write reference graph: %v", err)
+				}
+				if err := writeGraphs(*graphDir, "sut", sutState, onlySUT); err != nil {
+					logger.Printf("failed to write SUT graph: %v", err)
+				}
+			}
+			fatalf("State Mismatch! SUT (%q schema changer), Reference (%q schema changer)\n%s", sutSchemaChanger, refSchemaChanger, diff)
+		}
+
+		if *changefeeds {
+			if want := pkg.ExpectedChangefeedEvent(cmd, state); changefeedEvent != want {
+				fatalf("Changefeed Event Mismatch! (%q schema changer)\n\tSUT: %s\n\tExpected: %s", sutSchemaChanger, changefeedEvent, want)
+			}
+		}
+
+		// Validation runs before other checks so that corruption is
+		// attributed to the command rather than the check's own DDL.
+		if *validateDescriptors {
+			if err := sut.(pkg.DescriptorValidator).ValidateDescriptors(ctx); err != nil {
+				fatalf("Descriptor Corruption after step %d: %s!\n%v", i, pkg.CommandToString(cmd), err)
+			}
+		}
+
+		if *crossCheck {
+			if err := sut.(pkg.CrossChecker).CrossCheck(ctx); err != nil {
+				fatalf("Introspection Mismatch!\n%v", err)
+			}
+		}
+
+		if *roundTrip {
+			if err := sut.(pkg.RoundTripper).RoundTrip(ctx); err != nil {
+				fatalf("Round Trip Mismatch!\n%v", err)
+			}
+		}
+
+		// The rate is checked first so that, when disabled, the random
+		// draws, and therefore the workload generated from a seed, are
+		// unchanged. There may be no databases to back up.
+		if *backupRestoreRate > 0 && rand.Float64() < *backupRestoreRate {
+			if db, err := dag.Nodes[*pkg.Database](state).TryAny(); err == nil {
+				logger.Printf("Step %d: Backup/Restore %s (seed: %d)", i, db.Name, seed)
+				if err := sut.(pkg.BackupRestorer).BackupRestore(ctx, db.Name); err != nil {
+					fatalf("Backup/Restore Mismatch of %s!\n%v", db.Name, err)
+				}
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/cockroachdb/cockroach-go/v2/testserver"
+	"github.com/cockroachdb/errors"
+	"github.com/jmoiron/sqlx"
+)
+
+// upgrader performs a rolling binary upgrade of the SUT cluster, one node
+// per call to Next, followed by finalizing the cluster version.
+type upgrader struct {
+	ts     testserver.TestServer
+	db     *sqlx.DB
+	nodes  int
+	logger *log.Logger
+
+	// from is the cluster version prior to the upgrade.
+	from string
+	// next is the index of the next node to upgrade. Once next equals nodes,
+	// the cluster is finalized.
+	next      int
+	finalized bool
+}
+
+// upgradeOpts returns the TestServerOpts required to upgrade a cluster of
+// nodes to the binary at path. Nodes must retain their data and addresses
+// across restarts, so stores are written to disk and listen ports are fixed.
+// Nodes beyond testserver's are configured by [clusterConfig] instead.
+func upgradeOpts(nodes int, path string) ([]testserver.TestServerOpt, error) {
+	opts := []testserver.TestServerOpt{
+		testserver.UpgradeCockroachBinaryPathOpt(path),
+		testserver.StoreOnDiskOpt(),
+	}
+
+	if nodes > testserverNodes {
+		nodes = testserverNodes
+	}
+	for i := 0; i < nodes; i++ {
+		port, err := freePort()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, testserver.AddListenAddrPortOpt(port))
+	}
+
+	return opts, nil
+}
+
+// newUpgrader prevents ts from automatically finalizing once all of its
+// nodes have been upgraded.
+func newUpgrader(ctx context.Context, ts testserver.TestServer, nodes int, logger *log.Logger) (*upgrader, error) {
+	db, err := openSystemDB(ts)
+	if err != nil {
+		return nil, err
+	}
+
+	u := &upgrader{ts: ts, db: db, nodes: nodes, logger: logger}
+
+	if err := db.GetContext(ctx, &u.from, `SHOW CLUSTER SETTING version`); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if _, err := db.ExecContext(ctx, `SET CLUSTER SETTING cluster.preserve_downgrade_option = $1`, u.from); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return u, nil
+}
+
+// Steps is the total number of calls to Next required to complete the
+// upgrade.
+func (u *upgrader) Steps() int {
+	return u.nodes + 1
+}
+
+// Done reports whether the upgrade has been finalized.
+func (u *upgrader) Done() bool {
+	return u.finalized
+}
+
+// Next upgrades the next node or, if all nodes have been upgraded, finalizes
+// the upgrade. It returns a description of the phase that was completed.
+func (u *upgrader) Next(ctx context.Context) (string, error) {
+	if u.next < u.nodes {
+		node := u.next
+		u.next++
+
+		u.logger.Printf("Upgrading node %d", node)
+		if err := u.ts.UpgradeNode(node); err != nil {
+			return "", errors.Wrapf(err, "upgrading node %d", node)
+		}
+		if err := u.ts.WaitForInitFinishForNode(node); err != nil {
+			return "", errors.Wrapf(err, "waiting for node %d", node)
+		}
+		return fmt.Sprintf("upgrading node %d", node), nil
+	}
+
+	u.logger.Printf("Finalizing upgrade from %s", u.from)
+	if _, err := u.db.ExecContext(ctx, `RESET CLUSTER SETTING cluster.preserve_downgrade_option`); err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+
+	for {
+		var version string
+		// Connections may fail while the gateway restarts, keep polling.
+		if err := u.db.GetContext(ctx, &version, `SHOW CLUSTER SETTING version`); err == nil && version != u.from {
+			u.finalized = true
+			return fmt.Sprintf("finalizing %s to %s", u.from, version), nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", errors.Wrapf(ctx.Err(), "waiting for %s to finalize", u.from)
+		case <-time.After(time.Second):
+		}
+	}
+}